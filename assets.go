@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fotodeck/internal/application"
+	"fotodeck/internal/handler"
+	"fotodeck/web"
+
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+)
+
+// webAssets returns the templates and static files of the site. They are embedded in the binary,
+// unless dev mode reads them from web.dir so edits show on refresh. Files of the web.theme directory
+// replace the built in ones.
+func webAssets(conf application.Config) (*handler.Templates, fs.FS, error) {
+	var assets fs.FS = web.Files
+	if conf.Web.Dev {
+		slog.Info("dev mode, serving templates and static files from disk", "dir", conf.Web.Dir)
+		assets = os.DirFS(conf.Web.Dir)
+	}
+	if conf.Web.Theme != "" {
+		stat, err := os.Stat(conf.Web.Theme)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open theme: %w", err)
+		}
+		if !stat.IsDir() {
+			return nil, nil, fmt.Errorf("theme '%s' is not a directory", conf.Web.Theme)
+		}
+		slog.Info("using theme", "dir", conf.Web.Theme)
+		assets = handler.ThemeFS(os.DirFS(conf.Web.Theme), assets)
+	}
+
+	templates, err := handler.NewTemplates(assets, conf.Web.Dev)
+	if err != nil {
+		return nil, nil, err
+	}
+	static, err := fs.Sub(assets, "static")
+	if err != nil {
+		return nil, nil, err
+	}
+	return templates, static, nil
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"fotodeck/internal/application"
+	"fotodeck/internal/images"
+	"fotodeck/internal/share"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func runCleanup(args []string) int {
+	fs, common := newFlagSet("cleanup")
+	dryRun := fs.Bool("dry-run", false, "list the files that would be removed without removing them")
+	conf, code, ok := parse(fs, common, args)
+	if !ok {
+		return code
+	}
+	failed := 0
+	for _, library := range conf.EffectiveLibraries() {
+		failed += cleanupLibrary(library, *dryRun)
+	}
+	if failed > 0 {
+		return exitFailure
+	}
+	return exitOk
+}
+
+// cleanupLibrary removes the resized images of a library, returning the number of failures
+func cleanupLibrary(library application.Library, dryRun bool) int {
+	loader := application.NewLoader(library)
+
+	fmt.Println("Cleaning up image previews for library: ", library.Path)
+	failed := 0
+	err := filepath.WalkDir(library.Path, func(path string, f os.DirEntry, err error) error {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Walkdir error: ", path, err)
+			return nil
+		}
+		if f.IsDir() || !loader.IsResizedImage(f.Name()) {
+			return nil
+		}
+
+		if dryRun {
+			fmt.Println("Would remove file: ", path)
+			return nil
+		}
+		fmt.Println("Removing file: ", path)
+		err = os.Remove(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to remove file: ", path, err)
+			failed++
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error cleaning up library: ", err)
+		failed++
+	}
+	return failed
+}
+
+func runOptimise(args []string) int {
+	fs, common := newFlagSet("optimise")
+	dryRun := fs.Bool("dry-run", false, "list the resized images that would be created without creating them")
+	conf, code, ok := parse(fs, common, args)
+	if !ok {
+		return code
+	}
+
+	code = exitOk
+	for _, library := range conf.EffectiveLibraries() {
+		loader := application.NewLoader(library)
+		fileEntries, err := loader.LoadOriginals(library.Path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error loading library: ", err)
+			return exitFailure
+		}
+
+		if *dryRun {
+			problems, err := loader.VerifyImages(fileEntries)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error verifying images: ", err)
+				return exitFailure
+			}
+			for _, p := range problems {
+				fmt.Printf("Would create %s (%s)\n", p.Path, p.Status)
+			}
+			fmt.Printf("%d resized images would be created in %s\n", len(problems), library.Path)
+			continue
+		}
+
+		err = loader.OptimiseImages(&fileEntries)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error optimising images: ", err)
+			return exitFailure
+		}
+		problems, err := loader.VerifyImages(fileEntries)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error verifying images: ", err)
+			return exitFailure
+		}
+		for _, p := range problems {
+			fmt.Fprintf(os.Stderr, "Failed to create %s (%s)\n", p.Path, p.Status)
+		}
+		fmt.Printf("Optimised %d images in %s\n", len(fileEntries), library.Path)
+		if len(problems) > 0 {
+			code = exitFailure
+		}
+	}
+	return code
+}
+
+func runVerify(args []string) int {
+	fs, common := newFlagSet("verify")
+	fix := fs.Bool("fix", false, "regenerate missing, corrupt and stale resized images")
+	conf, code, ok := parse(fs, common, args)
+	if !ok {
+		return code
+	}
+
+	total, found, unresolved := 0, 0, 0
+	for _, library := range conf.EffectiveLibraries() {
+		loader := application.NewLoader(library)
+		fileEntries, err := loader.LoadOriginals(library.Path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error loading library: ", err)
+			return exitFailure
+		}
+		verify := loader.VerifyImages
+		if *fix {
+			verify = loader.RepairImages
+		}
+		problems, err := verify(fileEntries)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error verifying images: ", err)
+			return exitFailure
+		}
+
+		for _, p := range problems {
+			status := p.Status.String()
+			if p.Repaired {
+				status = "fixed " + status
+			} else {
+				unresolved++
+			}
+			if p.Err != nil {
+				fmt.Printf("%-14s %s: %v\n", status, p.Path, p.Err)
+			} else {
+				fmt.Printf("%-14s %s\n", status, p.Path)
+			}
+		}
+		total += len(fileEntries)
+		found += len(problems)
+	}
+
+	fmt.Printf("Verified %d images, found %d problems, fixed %d\n", total, found, found-unresolved)
+	if unresolved > 0 {
+		return exitFailure
+	}
+	return exitOk
+}
+
+func runStats(args []string) int {
+	fs, common := newFlagSet("stats")
+	conf, code, ok := parse(fs, common, args)
+	if !ok {
+		return code
+	}
+
+	catalog := make(map[string]images.ImageFile)
+	for _, library := range conf.EffectiveLibraries() {
+		loader := application.NewLoader(library)
+		fileEntries, err := loader.LoadOriginals(library.Path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error loading library: ", err)
+			return exitFailure
+		}
+		problems, err := loader.VerifyImages(fileEntries)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error verifying images: ", err)
+			return exitFailure
+		}
+		err = loader.HashImages(&fileEntries)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error hashing images: ", err)
+			return exitFailure
+		}
+
+		var originalBytes, derivativeBytes int64
+		derivativeCount := 0
+		for key, entry := range fileEntries {
+			catalog[images.LibraryID(library.Name, key)] = entry
+			originalBytes += fileSize(entry.GetFullSize())
+			for _, path := range loader.DerivativePaths(entry) {
+				size := fileSize(path)
+				if size > 0 {
+					derivativeCount++
+					derivativeBytes += size
+				}
+			}
+		}
+
+		if library.Name != "" {
+			fmt.Printf("Library:            %s\n", library.Name)
+		}
+		fmt.Printf("Path:               %s\n", library.Path)
+		fmt.Printf("Original images:    %d (%s)\n", len(fileEntries), formatBytes(originalBytes))
+		fmt.Printf("Resized images:     %d (%s)\n", derivativeCount, formatBytes(derivativeBytes))
+		fmt.Printf("Derivative issues:  %d\n\n", len(problems))
+	}
+
+	duplicateCount := 0
+	for _, group := range images.FindDuplicates(catalog) {
+		duplicateCount += len(group.Files) - 1
+	}
+	fmt.Printf("Duplicate images:   %d\n", duplicateCount)
+	return exitOk
+}
+
+func runDuplicates(args []string) int {
+	fs, common := newFlagSet("duplicates")
+	conf, code, ok := parse(fs, common, args)
+	if !ok {
+		return code
+	}
+	fileEntries, err := loadCatalog(conf, (*images.Loader).HashImages)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading libraries: ", err)
+		return exitFailure
+	}
+
+	groups := images.FindDuplicates(fileEntries)
+	for _, group := range groups {
+		fmt.Println(group.Hash)
+		for _, key := range group.Files {
+			entry := fileEntries[key]
+			fmt.Println("    ", entry.GetFullSize())
+		}
+	}
+	fmt.Printf("Found %d groups of duplicate images\n", len(groups))
+	return exitOk
+}
+
+func runSimilar(args []string) int {
+	fs, common := newFlagSet("similar")
+	threshold := fs.Int("threshold", -1, "maximum Hamming distance between similar images (default from config)")
+	conf, code, ok := parse(fs, common, args)
+	if !ok {
+		return code
+	}
+	if *threshold < 0 {
+		*threshold = conf.Similarity.Threshold
+	}
+	fileEntries, err := loadCatalog(conf, (*images.Loader).PerceptualHashImages)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading libraries: ", err)
+		return exitFailure
+	}
+
+	clusters := images.ClusterSimilar(fileEntries, *threshold)
+	for i, cluster := range clusters {
+		fmt.Printf("Cluster %d\n", i+1)
+		for _, key := range cluster {
+			entry := fileEntries[key]
+			fmt.Println("    ", entry.GetFullSize())
+		}
+	}
+	fmt.Printf("Found %d clusters of similar images (threshold %d)\n", len(clusters), *threshold)
+	return exitOk
+}
+
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintln(os.Stderr, "USAGE: ./fotodeck-helper config check [-config PATH]")
+		return exitUsage
+	}
+	fs, common := newFlagSet("config check")
+	err := fs.Parse(args[1:])
+	if err != nil {
+		return exitUsage
+	}
+
+	conf, warnings, err := application.CheckConfig(common.configPath)
+	for _, warning := range warnings {
+		fmt.Println("WARNING:", warning)
+	}
+	if err != nil {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Println("ERROR:  ", line)
+		}
+		return exitFailure
+	}
+	conf, err = selectLibraries(conf, common)
+	if err != nil {
+		fmt.Println("ERROR:  ", err)
+		return exitFailure
+	}
+	err = application.ValidateLibraryPaths(conf)
+	if err != nil {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Println("ERROR:  ", line)
+		}
+		return exitFailure
+	}
+
+	fmt.Println("Config OK: ", common.configPath)
+	return exitOk
+}
+
+// runShare prints a signed link to an album or photo that works without logging in
+func runShare(args []string) int {
+	fs, common := newFlagSet("share")
+	album := fs.String("album", "", "name of the [[libraries]] entry to share, [home] has no album to share")
+	photo := fs.String("photo", "", "ID of the photo to share, e.g. family:beach.jpg")
+	expires := fs.Duration("expires", 0, "how long the link is valid for, e.g. 48h (default sharing.defaultExpiry)")
+	baseUrl := fs.String("base-url", "", "address of the server the link is for, e.g. https://photos.example.com")
+	conf, code, ok := parse(fs, common, args)
+	if !ok {
+		return code
+	}
+	if conf.Sharing.Secret == "" {
+		fmt.Fprintln(os.Stderr, "sharing is disabled, set sharing.secret in the config")
+		return exitFailure
+	}
+	if (*album == "") == (*photo == "") {
+		fmt.Fprintln(os.Stderr, "exactly one of -album and -photo must be set")
+		return exitUsage
+	}
+
+	scope, id, library := share.ScopeAlbum, *album, *album
+	if *photo != "" {
+		scope, id = share.ScopePhoto, *photo
+		library, _ = images.SplitLibraryID(*photo)
+	}
+	if _, ok := conf.Library(library); !ok {
+		fmt.Fprintln(os.Stderr, "library not found in config: ", library)
+		return exitFailure
+	}
+
+	lifetime := *expires
+	if lifetime == 0 {
+		lifetime = time.Duration(conf.Sharing.DefaultExpiry) * time.Second
+	}
+	token, err := share.New(scope, id, lifetime)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	fmt.Fprintln(os.Stderr, "Link expires: ", token.ExpiresAt().Format(time.RFC1123))
+	fmt.Println(strings.TrimSuffix(*baseUrl, "/") + "/share/" + share.Sign([]byte(conf.Sharing.Secret), token))
+	return exitOk
+}
+
+// runHashPassword prints the bcrypt hash of a password read from stdin, for [[users]] passwordHash
+func runHashPassword(args []string) int {
+	fs := flag.NewFlagSet("hash-password", flag.ContinueOnError)
+	cost := fs.Int("cost", bcrypt.DefaultCost, "bcrypt cost, higher is slower to check and to crack")
+	err := fs.Parse(args)
+	if err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "unexpected arguments: %v\n", fs.Args())
+		return exitUsage
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		fmt.Fprintln(os.Stderr, "failed to read password: ", err)
+		return exitFailure
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		fmt.Fprintln(os.Stderr, "password must not be empty")
+		return exitFailure
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), *cost)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to hash password: ", err)
+		return exitFailure
+	}
+	fmt.Println(string(hash))
+	return exitOk
+}
+
+// loadCatalog loads the originals of every library, runs prepare over each library,
+// and merges the results keyed by library ID
+func loadCatalog(conf application.Config, prepare func(*images.Loader, *map[string]images.ImageFile) error) (map[string]images.ImageFile, error) {
+	catalog := make(map[string]images.ImageFile)
+	for _, library := range conf.EffectiveLibraries() {
+		loader := application.NewLoader(library)
+		fileEntries, err := loader.LoadOriginals(library.Path)
+		if err != nil {
+			return nil, err
+		}
+		err = prepare(&loader, &fileEntries)
+		if err != nil {
+			return nil, err
+		}
+		for key, entry := range fileEntries {
+			catalog[images.LibraryID(library.Name, key)] = entry
+		}
+	}
+	return catalog, nil
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
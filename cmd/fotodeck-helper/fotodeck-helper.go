@@ -1,41 +1,131 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"fotodeck/internal/application"
 	"os"
 	"path/filepath"
-	"strings"
 )
 
+const (
+	exitOk      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+var commands = []command{
+	{"cleanup", "remove resized images from the home path", runCleanup},
+	{"optimise", "pre-generate resized images without starting the server", runOptimise},
+	{"verify", "find missing, corrupt or stale resized images", runVerify},
+	{"stats", "print library statistics", runStats},
+	{"duplicates", "list byte-identical images", runDuplicates},
+	{"similar", "list clusters of visually similar images", runSimilar},
+	{"config", "'config check' validates the config file", runConfig},
+	{"share", "print a signed, expiring link to an album or photo", runShare},
+	{"hash-password", "hash a password read from stdin for a [[users]] entry", runHashPassword},
+}
+
 func main() {
-	if len(os.Args) != 3 {
-		fmt.Println("USAGE: ./fotodeck-helper <COMMAND> <HOME PATH>\nValid commands: [cleanup,]")
-		os.Exit(1)
-	}
-	command := os.Args[1]
-	homePath := os.Args[2]
-
-	if command == "cleanup" {
-		fmt.Println("Cleaning up image previews for homePath: ", homePath)
-		err := filepath.WalkDir(homePath, func(path string, f os.DirEntry, err error) error {
-			if err != nil {
-				fmt.Println("Walkdir error: ", path, err)
-			}
-			if !strings.Contains(f.Name(), ".opt.") && !strings.Contains(f.Name(), ".prev.") {
-				return nil
-			}
-
-			fmt.Println("Removing file: ", path)
-			err = os.Remove(path)
-			if err != nil {
-				fmt.Println("Failed to remove file: ", path, err)
-			}
-
-			return nil
-		})
-		if err != nil {
-			fmt.Println("Error cleaning up homePath: ", err)
-			os.Exit(1)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitUsage)
+	}
+
+	name := os.Args[1]
+	for _, c := range commands {
+		if c.name == name {
+			os.Exit(c.run(os.Args[2:]))
+		}
+	}
+
+	if name != "help" && name != "-h" && name != "--help" {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", name)
+	}
+	usage()
+	os.Exit(exitUsage)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "USAGE: ./fotodeck-helper <COMMAND> [FLAGS]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun './fotodeck-helper <COMMAND> -h' for command flags.")
+}
+
+// commonFlags are accepted by every command
+type commonFlags struct {
+	configPath string
+	homePath   string
+	library    string
+}
+
+func newFlagSet(name string) (*flag.FlagSet, *commonFlags) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	common := &commonFlags{}
+	fs.StringVar(&common.configPath, "config", "config.toml", "path to the fotodeck config file")
+	fs.StringVar(&common.homePath, "home", "", "use this path as the only library instead of those in the config file")
+	fs.StringVar(&common.library, "library", "", "only process the named library")
+	return fs, common
+}
+
+// parse parses the command flags and loads the config. ok is false when the command should exit with code.
+func parse(fs *flag.FlagSet, common *commonFlags, args []string) (conf application.Config, code int, ok bool) {
+	err := fs.Parse(args)
+	if err != nil {
+		return conf, exitUsage, false
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "unexpected arguments: %v\n", fs.Args())
+		return conf, exitUsage, false
+	}
+
+	envValues, envWarnings := application.EnvOverrides(os.Environ())
+	conf, warnings, err := application.LoadConfigWithOverrides(common.configPath, application.Overrides{Env: envValues})
+	for _, warning := range append(envWarnings, warnings...) {
+		fmt.Fprintln(os.Stderr, "WARNING:", warning)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to load config: ", err)
+		return conf, exitFailure, false
+	}
+	conf, err = selectLibraries(conf, common)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return conf, exitUsage, false
+	}
+	err = application.ValidateLibraryPaths(conf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return conf, exitFailure, false
+	}
+	return conf, exitOk, true
+}
+
+// selectLibraries narrows the configured libraries down to those chosen by the -home and -library flags
+func selectLibraries(conf application.Config, common *commonFlags) (application.Config, error) {
+	if common.homePath != "" {
+		conf.Home.Path = filepath.Clean(common.homePath)
+		conf.Libraries = nil
+		return conf, nil
+	}
+	if common.library == "" {
+		return conf, nil
+	}
+
+	for _, library := range conf.Libraries {
+		if library.Name == common.library {
+			conf.Libraries = append(conf.Libraries[:0:0], library)
+			return conf, nil
 		}
 	}
+	return conf, fmt.Errorf("library not found in config: %s", common.library)
 }
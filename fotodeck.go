@@ -3,10 +3,12 @@ package main
 import (
 	"fotodeck/internal/application"
 	"fotodeck/internal/handler"
-	"fotodeck/internal/images"
+	"fotodeck/internal/metrics"
 
 	"context"
+	"crypto/tls"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"log/slog"
@@ -15,107 +17,184 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
-
-	"github.com/fsnotify/fsnotify"
-	"github.com/samber/lo"
 )
 
 func main() {
 	// --- Setup ---
-	if len(os.Args) != 2 {
-		fmt.Println("USAGE: ./fotodeck <CONFIG PATH>")
+	conf, configPath, overrides := loadConfig()
+	logLevel := &slog.LevelVar{}
+	accessLogger, closeAccessLog, err := setupLogging(conf, logLevel)
+	if err != nil {
+		slog.Error("failed to open access log", "path", conf.Log.AccessFile, "error", err)
 		os.Exit(1)
 	}
+	defer closeAccessLog()
 
-	configPath := os.Args[1]
-	conf, err := application.LoadConfig(configPath)
+	err = application.ValidateLibraryPaths(conf)
 	if err != nil {
-		slog.Error("failed to load application config", "path", configPath, "error", err.Error())
+		slog.Error("failed to validate library paths", "error", err.Error())
 		os.Exit(1)
 	}
-	err = application.ValidateHomePath(conf)
+
+	// --- Load files and watch for changes ---
+	// libraries load in the background while the server starts, /readyz reports when they are done
+	fileHolder := handler.FileHolder{}
+	health := handler.Health{}
+	libraries := conf.EffectiveLibraries()
+	updates := make([]chan application.Config, len(libraries))
+	for i, library := range libraries {
+		// buffered so config reloads never wait for a library, see sendLatest
+		updates[i] = make(chan application.Config, 1)
+		health.AddLibrary(library.Name, library.Path)
+	}
+	go func() {
+		// one at a time, as optimising already uses every CPU
+		for i, library := range libraries {
+			startLibrary(conf, library, &fileHolder, &health, updates[i])
+		}
+	}()
+
+	// --- Static file servers ---
+	templates, static, err := webAssets(conf)
 	if err != nil {
-		slog.Error("failed to validate home path", "path", conf.Home.Path, "error", err.Error())
+		slog.Error("failed to load web assets", "error", err.Error())
 		os.Exit(1)
 	}
+	publicServer := http.FileServer(http.FS(static))
+	handle("/public/", http.StripPrefix("/public/", publicServer))
 
-	// --- Load files ---
-	loader := images.Loader{
-		OptimisedExtension: conf.ImageResizing.ResizedFileExtension,
-		PreviewExtension:   conf.ImageResizing.PreviewFileExtension,
-		MaxOptimisedDimensions: images.Dimensions{
-			Width:  conf.ImageResizing.ResizedWidth,
-			Height: conf.ImageResizing.ResizedHeight,
-		},
-		MaxPreviewDimensions: images.Dimensions{
-			Width:  conf.ImageResizing.PreviewWidth,
-			Height: conf.ImageResizing.PreviewHeight,
+	// --- Watch for config changes ---
+	siteSettings := handler.SiteSettings{}
+	siteSettings.Set(conf.Gallery.Title, conf.Gallery.Sort)
+	siteSettings.SetPageSize(conf.Gallery.PageSize)
+	siteSettings.SetSlideshow(slideshowSettings(conf))
+
+	reloader := configReloader{
+		path:           configPath,
+		overrides:      overrides,
+		current:        conf,
+		settings:       &siteSettings,
+		logLevel:       logLevel,
+		libraryUpdates: updates,
+	}
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go reloader.run(hupChan)
+
+	// --- Routes ---
+	auth := newAuth(conf, templates)
+
+	rootHandler := handler.RootHandler{
+		FileHolder: &fileHolder,
+		Settings:   &siteSettings,
+		Auth:       auth,
+		Templates:  templates,
+		MapSettings: handler.MapSettings{
+			TileURL:     conf.Map.TileUrl,
+			Attribution: conf.Map.Attribution,
+			MaxZoom:     conf.Map.MaxZoom,
 		},
 	}
-	var fileEntries map[string]images.ImageFile
-	fileEntries, fileLoadErr := loader.LoadOriginals(conf.Home.Path)
-	if fileLoadErr != nil {
-		log.Fatal(fileLoadErr)
+
+	// full size images are the most expensive responses, so share one cap across their routes.
+	// Previews are small and already resized, so their routes are deliberately left uncapped
+	fullSize := func(h http.Handler) http.Handler { return h }
+	if conf.RateLimit.MaxConcurrentFullSize > 0 {
+		fullSize = handler.NewConcurrencyLimiter(conf.RateLimit.MaxConcurrentFullSize).Middleware
 	}
-	fileLoadErr = loader.OptimiseImages(&fileEntries)
-	if fileLoadErr != nil {
-		slog.Error("failed to optimimise images: ", "error", fileLoadErr)
+
+	imageHandler := handler.ImageHandler{
+		FileHolder: &fileHolder,
+		Auth:       auth,
 	}
-	fileHolder := handler.FileHolder{
-		Files: lo.Keys(fileEntries),
+
+	apiHandler := handler.ApiHandler{
+		FileHolder:          &fileHolder,
+		Settings:            &siteSettings,
+		SimilarityThreshold: conf.Similarity.Threshold,
+		Auth:                auth,
 	}
-	log.Printf("Found %d photos in %s", len(fileHolder.Files), conf.Home.Path)
 
-	// --- Watch for file changes ---
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		slog.Error("failed to initialise file watcher. File watch will be disabled", "error", err)
+	var appHandler http.Handler = http.DefaultServeMux
+	if auth != nil {
+		handleFunc("/login", auth.Login)
+		handleFunc("/logout", auth.Logout)
+		appHandler = auth.Middleware(appHandler)
+	}
+	appHandler = handler.LimitBodySize(int64(conf.Server.MaxBodyBytes), appHandler)
+	if conf.RateLimit.Enabled {
+		// before authentication, so password guessing is limited too
+		rateLimiter := handler.RateLimiter{
+			Rate:  float64(conf.RateLimit.RequestsPerSecond),
+			Burst: conf.RateLimit.Burst,
+		}
+		appHandler = rateLimiter.Middleware(appHandler)
 	}
-	if watcher != nil {
-		defer watcher.Close()
+	if accessLogger != nil {
+		accessLog := handler.AccessLog{Logger: accessLogger}
+		appHandler = accessLog.Middleware(appHandler)
 	}
+	// outermost, so every other middleware sees the real client address
+	clientIP := handler.ClientIP{TrustedProxies: conf.TrustedProxies()}
+	appHandler = clientIP.Middleware(appHandler)
 
-	throttle := time.NewTicker(time.Duration(conf.Home.MinRefreshInterval) * time.Second)
-	defer throttle.Stop()
+	handleFunc("/healthz", health.Healthz)
+	handleFunc("/readyz", health.Readyz)
 
-	go fileWatchFn(watcher, &loader, &fileHolder, throttle, conf.Home.Path)
+	handleFunc("/api/duplicates", apiHandler.Duplicates)
+	handleFunc("/api/photos/{id}/similar", apiHandler.Similar)
+	handleFunc("/api/search", apiHandler.Search)
+	handleFunc("/api/timeline", apiHandler.Timeline)
+	handleFunc("/api/geo", apiHandler.Geo)
+	handleFunc("/api/slideshow", apiHandler.Slideshow)
 
-	err = watcher.Add(conf.Home.Path)
-	if err != nil {
-		slog.Error("failed to add home path to file watcher. File watch will be disabled", "error", err)
-	}
+	handleFunc("/img/preview/{id}", imageHandler.Previews)
 
-	// --- Static file servers ---
-	publicServer := http.FileServer(http.Dir("./web/static"))
-	http.Handle("/public/", http.StripPrefix("/public/", publicServer))
+	handle("/img/{id}", fullSize(http.HandlerFunc(imageHandler.Images)))
 
-	// --- Routes ---
-	rootHandler := handler.RootHandler{
-		FileHolder: &fileHolder,
-	}
+	handleFunc("/albums/{name}", rootHandler.Album)
+	handleFunc("/search", rootHandler.Search)
+	handleFunc("/timeline", rootHandler.Timeline)
+	handleFunc("/map", rootHandler.Map)
+	handleFunc("/slideshow", rootHandler.Slideshow)
 
-	imageHandler := handler.ImageHandler{
-		FileEntries: fileEntries,
+	if conf.Sharing.Secret != "" {
+		shareHandler := handler.ShareHandler{
+			FileHolder:    &fileHolder,
+			Settings:      &siteSettings,
+			Auth:          auth,
+			Templates:     templates,
+			Secret:        []byte(conf.Sharing.Secret),
+			DefaultExpiry: time.Duration(conf.Sharing.DefaultExpiry) * time.Second,
+		}
+		handleFunc("/api/share", shareHandler.Create)
+		handleFunc("/share/{token}", shareHandler.View)
+		handleFunc("/share/{token}/img/preview/{id}", shareHandler.Previews)
+		handle("/share/{token}/img/{id}", fullSize(http.HandlerFunc(shareHandler.Images)))
 	}
 
-	http.HandleFunc("/img/preview/{id}", imageHandler.Previews)
-
-	http.HandleFunc("/img/{id}", imageHandler.Images)
+	handleFunc("/", rootHandler.Index)
 
-	http.HandleFunc("/", rootHandler.Index)
+	if conf.Metrics.Enabled {
+		handle("/metrics", metrics.Handler())
+	}
 
 	// --- Run ---
-	server := &http.Server{
-		Addr:              conf.Server.ListenAddr,
-		ReadTimeout:       5 * time.Second,
-		ReadHeaderTimeout: 3 * time.Second,
-		WriteTimeout:      10 * time.Second,
+	server, err := newServer(conf, appHandler)
+	if err != nil {
+		slog.Error("failed to configure server", "error", err.Error())
+		os.Exit(1)
 	}
 
 	go func() {
-		slog.Info("starting server", "addr", conf.Server.ListenAddr)
-		// #nosec G114 -- headers are set above
-		if err := http.ListenAndServe(conf.Server.ListenAddr, logRequest(http.DefaultServeMux)); !errors.Is(err, http.ErrServerClosed) {
+		slog.Info("starting server", "addr", conf.Server.ListenAddr, "tls", server.TLSConfig != nil)
+		if server.TLSConfig != nil {
+			// certificates are provided by TLSConfig.GetCertificate
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
 			log.Fatal(err)
 		}
 		slog.Info("Stopped serving new connections.")
@@ -130,11 +209,9 @@ func main() {
 	defer shutdownRelease()
 
 	if conf.ImageResizing.CleanupOnShutdown {
-		err = watcher.Close()
-		if err != nil {
-			slog.Error("Failed to close watcher", "error", err)
-		}
-		for _, v := range fileEntries {
+		fileHolder.Mu.RLock()
+		defer fileHolder.Mu.RUnlock()
+		for _, v := range fileHolder.Entries {
 			err := v.Cleanup()
 			if err != nil {
 				slog.Error("error cleaning up file", "file", v.Name(), "error", err)
@@ -149,42 +226,127 @@ func main() {
 	slog.Info("Graceful shutdown complete.")
 }
 
-func logRequest(handler http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		slog.Info("HttpServer", "remoteAddr", r.RemoteAddr, "method", r.Method, "url", r.URL)
-		handler.ServeHTTP(w, r)
-	})
+// loadConfig layers the defaults, the config file, FOTODECK_* environment variables
+// and command line flags into the effective config. Exits on invalid config.
+// The config path and overrides are returned so the config can be reloaded later.
+func loadConfig() (application.Config, string, application.Overrides) {
+	fs := flag.NewFlagSet("fotodeck", flag.ExitOnError)
+	configFlag := fs.String("config", "", "path to the config file (env "+application.EnvPrefix+"CONFIG)")
+	printConfig := fs.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	dev := fs.Bool("dev", false, "serve templates and static files from web.dir, reloading edits on refresh (same as -web.dev)")
+	flagValues := application.RegisterFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "USAGE: ./fotodeck [FLAGS] [CONFIG PATH]")
+		fs.PrintDefaults()
+		fmt.Fprintln(fs.Output(), "[[libraries]] and [[users]] can only be set in the config file.")
+	}
+	_ = fs.Parse(os.Args[1:])
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *dev {
+		flagValues["web.dev"] = "true"
+	}
+
+	configPath := *configFlag
+	if configPath == "" {
+		configPath = os.Getenv(application.EnvPrefix + "CONFIG")
+	}
+	if fs.NArg() == 1 {
+		configPath = fs.Arg(0)
+	}
+
+	envValues, envWarnings := application.EnvOverrides(os.Environ())
+	overrides := application.Overrides{
+		Env:   envValues,
+		Flags: flagValues,
+	}
+	conf, warnings, err := application.LoadConfigWithOverrides(configPath, overrides)
+	for _, warning := range append(envWarnings, warnings...) {
+		slog.Warn("config warning", "warning", warning)
+	}
+	if err != nil {
+		slog.Error("failed to load application config", "path", configPath, "error", err.Error())
+		os.Exit(1)
+	}
+
+	if *printConfig {
+		err = application.PrintConfig(os.Stdout, conf)
+		if err != nil {
+			slog.Error("failed to print config", "error", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	return conf, configPath, overrides
 }
 
-func fileWatchFn(watcher *fsnotify.Watcher, loader *images.Loader, fileHolder *handler.FileHolder, throttle *time.Ticker, homePath string) {
-	var hasNewEvent bool
+// handle registers h on the default mux, recording request metrics under its pattern
+func handle(pattern string, h http.Handler) {
+	http.Handle(pattern, handler.Instrument(pattern, h))
+}
 
-	for {
-		select {
-		case event, ok := <-watcher.Events:
-			if !ok {
-				return
-			}
-			// prevent circular update loop
-			if !loader.IsResizedImage(event.Name) {
-				slog.Info("watcherEvent", "event", event)
-				hasNewEvent = true
-			}
-		case err, ok := <-watcher.Errors:
-			if !ok {
-				return
-			}
-			slog.Error("watcherError: ", "err", err)
-		case <-throttle.C:
-			if hasNewEvent {
-				fileEntries, fileLoadErr := loader.Reload(homePath)
-				if fileLoadErr != nil {
-					slog.Error("failed to reload homePath", "path", fileLoadErr)
-				}
-				fileHolder.Set(lo.Keys(fileEntries))
-				slog.Info("watcherEvent: homePath refresh completed")
-				hasNewEvent = false
-			}
+func handleFunc(pattern string, h http.HandlerFunc) {
+	handle(pattern, h)
+}
+
+// newServer builds the server from the [server] options.
+// HTTPS is served when a certificate is configured, with HTTP/2 negotiated over TLS.
+func newServer(appConf application.Config, handler http.Handler) (*http.Server, error) {
+	conf := appConf.Server
+	server := &http.Server{
+		Addr:              conf.ListenAddr,
+		Handler:           handler,
+		ReadTimeout:       time.Duration(conf.ReadTimeout) * time.Second,
+		ReadHeaderTimeout: time.Duration(conf.ReadHeaderTimeout) * time.Second,
+		WriteTimeout:      time.Duration(conf.WriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(conf.IdleTimeout) * time.Second,
+		MaxHeaderBytes:    conf.MaxHeaderBytes,
+	}
+	if conf.TlsCertFile == "" {
+		return server, nil
+	}
+
+	certs, err := newCertReloader(conf.TlsCertFile, conf.TlsKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	server.TLSConfig = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1"},
+		GetCertificate: certs.GetCertificate,
+	}
+	return server, nil
+}
+
+// newAuth configures authentication from the [auth] and [[users]] options. Returns nil when no users are configured.
+func newAuth(conf application.Config, templates *handler.Templates) *handler.Auth {
+	if !conf.AuthEnabled() {
+		return nil
+	}
+
+	libraryUsers := make(map[string][]string)
+	for _, library := range conf.EffectiveLibraries() {
+		if len(library.Users) > 0 {
+			libraryUsers[library.Name] = library.Users
 		}
 	}
+	return &handler.Auth{
+		Users:           conf.PasswordHashes(),
+		LibraryUsers:    libraryUsers,
+		Required:        conf.Auth.Required,
+		SessionLifetime: time.Duration(conf.Auth.SessionLifetime) * time.Second,
+		Templates:       templates,
+	}
+}
+
+// slideshowSettings are the [slideshow] options, applied again when the config is reloaded
+func slideshowSettings(conf application.Config) handler.SlideshowSettings {
+	return handler.SlideshowSettings{
+		Interval:   conf.Slideshow.Interval,
+		Transition: conf.Slideshow.Transition,
+		Shuffle:    conf.Slideshow.Shuffle,
+		Album:      conf.Slideshow.Album,
+	}
 }
@@ -0,0 +1,58 @@
+package application
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type (
+	auth struct {
+		// require a login to view the gallery. Libraries with users set always require a login
+		Required bool
+		// seconds a login from the login page lasts
+		SessionLifetime int
+	}
+
+	// userConfig is a [[users]] entry
+	userConfig struct {
+		Name string
+		// bcrypt hash of the password, generated with 'fotodeck-helper hash-password'
+		PasswordHash string
+	}
+)
+
+// PasswordHashes returns the bcrypt password hash of each configured user, keyed by user name
+func (c Config) PasswordHashes() map[string]string {
+	hashes := make(map[string]string, len(c.Users))
+	for _, user := range c.Users {
+		hashes[user.Name] = user.PasswordHash
+	}
+	return hashes
+}
+
+// AuthEnabled is true when any users are configured
+func (c Config) AuthEnabled() bool {
+	return len(c.Users) > 0
+}
+
+func validateUsers(users []userConfig) []error {
+	var errs []error
+	seen := make(map[string]bool)
+	for i, u := range users {
+		field := fmt.Sprintf("users[%d]", i)
+		if u.Name == "" {
+			errs = append(errs, &FieldError{Field: field + ".name", Value: `""`, Message: "must be set"})
+		}
+		if seen[u.Name] {
+			errs = append(errs, &FieldError{Field: field + ".name", Value: u.Name, Message: "must be unique"})
+		}
+		seen[u.Name] = true
+		_, err := bcrypt.Cost([]byte(u.PasswordHash))
+		if err != nil {
+			// don't echo the value, it may be a plain text password
+			errs = append(errs, &FieldError{Field: field + ".passwordHash", Value: "<hidden>", Message: "must be a bcrypt hash, generate one with 'fotodeck-helper hash-password'"})
+		}
+	}
+	return errs
+}
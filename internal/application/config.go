@@ -6,6 +6,8 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 )
@@ -13,14 +15,108 @@ import (
 type (
 	Config struct {
 		Home          home
+		Gallery       gallery
 		Server        server
 		ImageResizing imageResizing
+		Similarity    similarity
+		Auth          auth
+		Sharing       sharing
+		RateLimit     rateLimit
+		Log           logging
+		Metrics       metricsConfig
+		Web           web
+		Map           mapConfig
+		Slideshow     slideshow
+		Libraries     []libraryConfig
+		Users         []userConfig
+	}
+
+	gallery struct {
+		Title string
+		// order of photos in the grid, one of SortOrders
+		Sort string
+		// photos per page, 0 to show every photo on one page
+		PageSize int
+	}
+
+	similarity struct {
+		// maximum Hamming distance between perceptual hashes for images to be considered similar
+		Threshold int
+	}
+
+	web struct {
+		// read templates and static files from Dir on every request instead of the copies
+		// embedded in the binary, so edits show without a restart
+		Dev bool
+		// directory containing the template and static directories, used in dev mode
+		Dir string
+		// directory of templates and static files replacing the built in ones of the same name
+		Theme string
+	}
+
+	slideshow struct {
+		// seconds each photo is shown
+		Interval int
+		// how the next photo replaces the current one, one of SlideshowTransitions
+		Transition string
+		// show the photos in random order instead of by name
+		Shuffle bool
+		// name of the only library to show, empty for the whole gallery
+		Album string
+	}
+
+	mapConfig struct {
+		// URL of the map tiles, with {z}, {x} and {y} replaced by the zoom level and tile coordinates.
+		// Point it at a local tile server to use the map offline
+		TileUrl string
+		// credit for the map data shown on the map, as most tile servers require
+		Attribution string
+		// deepest zoom level the tile server has tiles for
+		MaxZoom int
+	}
+
+	metricsConfig struct {
+		// serve Prometheus metrics on /metrics
+		Enabled bool
+	}
+
+	logging struct {
+		// one of debug, info, warn or error
+		Level string
+		// one of LogFormats
+		Format string
+		// log a record of every request
+		Access bool
+		// write access logs to this file instead of the main log. All requests are recorded
+		// regardless of level
+		AccessFile string
+	}
+
+	rateLimit struct {
+		// limit the requests of each client IP with a token bucket. Behind a reverse proxy,
+		// server.trustedProxies must list it, or all clients share the proxy's limit
+		Enabled bool
+		// sustained requests per second allowed from each client IP
+		RequestsPerSecond int
+		// requests a client may make at once above the sustained rate
+		Burst int
+		// full size images served at once across all clients, 0 for no limit. Previews are
+		// not counted
+		MaxConcurrentFullSize int
+	}
+
+	sharing struct {
+		// key used to sign share links. Sharing is disabled when empty
+		Secret string `secret:"true"`
+		// seconds a share link is valid for when no expiry is given
+		DefaultExpiry int
 	}
 
 	imageResizing struct {
 		Async                bool
 		CleanupOnShutdown    bool
 		Enabled              bool
+		VerifyOnStartup      bool
 		PreviewWidth         int
 		PreviewHeight        int
 		ResizedWidth         int
@@ -31,52 +127,202 @@ type (
 
 	server struct {
 		ListenAddr string
+		// timeouts in seconds, 0 for no timeout
+		ReadTimeout       int
+		ReadHeaderTimeout int
+		WriteTimeout      int
+		IdleTimeout       int
+		// serve HTTPS when both are set. Renewed certificates are picked up without a restart
+		TlsCertFile string
+		TlsKeyFile  string
+		// largest request headers and body accepted, in bytes
+		MaxHeaderBytes int
+		MaxBodyBytes   int
+		// IPs or CIDR ranges of reverse proxies whose X-Forwarded-For header is trusted
+		TrustedProxies []string
 	}
 
 	home struct {
 		Path               string
 		MinRefreshInterval int
+		HideDuplicates     bool
+		// follow symlinks whose targets are within the library path or AllowedRoots
+		FollowSymlinks bool
+		AllowedRoots   []string
 	}
 )
 
+// DefaultConfig returns the values used for any option missing from the config file
+func DefaultConfig() Config {
+	return Config{
+		Home: home{
+			MinRefreshInterval: 10,
+		},
+		Gallery: gallery{
+			Title: "My Album",
+			Sort:  "random",
+		},
+		Server: server{
+			ListenAddr:        ":8080",
+			ReadTimeout:       5,
+			ReadHeaderTimeout: 3,
+			WriteTimeout:      10,
+			IdleTimeout:       120,
+			MaxHeaderBytes:    64 * 1024,
+			MaxBodyBytes:      1024 * 1024,
+		},
+		ImageResizing: imageResizing{
+			Enabled:              true,
+			VerifyOnStartup:      true,
+			ResizedWidth:         2000,
+			ResizedHeight:        2000,
+			PreviewWidth:         600,
+			PreviewHeight:        600,
+			ResizedFileExtension: "opt",
+			PreviewFileExtension: "prev",
+		},
+		Similarity: similarity{
+			Threshold: 10,
+		},
+		Auth: auth{
+			SessionLifetime: 7 * 24 * 60 * 60,
+		},
+		Metrics: metricsConfig{
+			Enabled: true,
+		},
+		Web: web{
+			Dir: "web",
+		},
+		Slideshow: slideshow{
+			Interval:   10,
+			Transition: "fade",
+			Shuffle:    true,
+		},
+		Map: mapConfig{
+			TileUrl:     "https://tile.openstreetmap.org/{z}/{x}/{y}.png",
+			Attribution: "© OpenStreetMap contributors",
+			MaxZoom:     19,
+		},
+		Log: logging{
+			Level:  "info",
+			Format: "text",
+			Access: true,
+		},
+		RateLimit: rateLimit{
+			Enabled:               true,
+			RequestsPerSecond:     20,
+			Burst:                 200,
+			MaxConcurrentFullSize: 16,
+		},
+		Sharing: sharing{
+			DefaultExpiry: 7 * 24 * 60 * 60,
+		},
+	}
+}
+
+// LoadConfig loads and validates the config file, logging a warning for every unknown key
 func LoadConfig(path string) (Config, error) {
+	conf, warnings, err := CheckConfig(path)
+	for _, warning := range warnings {
+		slog.Warn("config warning", "path", path, "warning", warning)
+	}
+	return conf, err
+}
+
+// CheckConfig loads the config file over the defaults and validates it.
+// Warnings are returned for keys in the file that don't match any option.
+func CheckConfig(path string) (Config, []string, error) {
+	return LoadConfigWithOverrides(path, Overrides{})
+}
+
+// LoadConfigWithOverrides builds the effective config from, in increasing priority: the defaults,
+// the config file at path (skipped when path is empty), overrides.Env and overrides.Flags.
+// The result is validated once all layers are applied.
+func LoadConfigWithOverrides(path string, overrides Overrides) (Config, []string, error) {
+	conf := DefaultConfig()
+	warnings := make([]string, 0)
+
+	if path != "" {
+		fileWarnings, err := decodeConfigFile(path, &conf)
+		if err != nil {
+			return Config{}, nil, err
+		}
+		warnings = append(warnings, fileWarnings...)
+	}
+
+	err := applyOverrides(&conf, overrides.Env, "environment variable for")
+	if err != nil {
+		return Config{}, warnings, err
+	}
+	err = applyOverrides(&conf, overrides.Flags, "flag")
+	if err != nil {
+		return Config{}, warnings, err
+	}
+
+	cleanLibraryPaths(&conf)
+
+	err = Validate(conf)
+	if err != nil {
+		return Config{}, warnings, err
+	}
+
+	return conf, warnings, nil
+}
+
+// decodeConfigFile decodes the TOML file at path over conf, returning warnings for unknown keys
+func decodeConfigFile(path string, conf *Config) ([]string, error) {
 	configPath := filepath.Clean(path)
 
 	configStat, err := os.Stat(configPath)
 	if errors.Is(err, os.ErrNotExist) {
 		slog.Error("path does not exist", "path", configPath)
-		return Config{}, fmt.Errorf("path does not exist: %s", path)
+		return nil, fmt.Errorf("path does not exist: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat config file '%s': %w", configPath, err)
 	}
 	if configStat.IsDir() {
-		return Config{}, fmt.Errorf("config path is a directory: %s", path)
+		return nil, fmt.Errorf("config path is a directory: %s", path)
 	}
 
 	configFileBytes, err := os.ReadFile(configPath)
 	if err != nil {
-		return Config{}, fmt.Errorf("failed to read config file '%s': %w", configPath, err)
+		return nil, fmt.Errorf("failed to read config file '%s': %w", configPath, err)
 	}
 	configFileString := string(configFileBytes)
 
-	var conf Config
-	_, err = toml.Decode(configFileString, &conf)
+	meta, err := toml.Decode(configFileString, conf)
 	if err != nil {
-		return Config{}, fmt.Errorf("TOML decode failed: %w", err)
+		return nil, fmt.Errorf("TOML decode failed: %w", err)
 	}
 	slog.Info("Loaded config file", "path", configPath)
 
-	conf.Home.Path = filepath.Clean(conf.Home.Path)
-
-	return conf, nil
+	warnings := make([]string, 0)
+	for _, key := range unknownKeys(meta) {
+		warnings = append(warnings, fmt.Sprintf("unknown config key '%s' will be ignored", key))
+	}
+	return warnings, nil
 }
 
-func ValidateHomePath(conf Config) error {
-	homePath := conf.Home.Path
-	s, err := os.Stat(conf.Home.Path)
-	if errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("home path does not exist: %s", homePath)
+// unknownKeys lists the undecoded keys, leaving out tables whose keys are already listed
+func unknownKeys(meta toml.MetaData) []string {
+	undecoded := make([]string, 0)
+	for _, key := range meta.Undecoded() {
+		undecoded = append(undecoded, key.String())
 	}
-	if !s.IsDir() {
-		return fmt.Errorf("home path is not a directory: %s", homePath)
+
+	keys := make([]string, 0, len(undecoded))
+	for _, key := range undecoded {
+		isTable := slices.ContainsFunc(undecoded, func(other string) bool {
+			return strings.HasPrefix(other, key+".")
+		})
+		if !isTable {
+			keys = append(keys, key)
+		}
 	}
-	return nil
+	return keys
+}
+
+func ValidateHomePath(conf Config) error {
+	return validateDirectory(conf.Home.Path)
 }
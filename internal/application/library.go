@@ -0,0 +1,170 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+)
+
+// library names become part of photo IDs and URLs
+var libraryNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// libraryConfig is a [[libraries]] entry. Unset options inherit from [home] and [imageResizing].
+type libraryConfig struct {
+	Name               string
+	Path               string
+	MinRefreshInterval int
+	PreviewWidth       *int
+	PreviewHeight      *int
+	ResizedWidth       *int
+	ResizedHeight      *int
+	// only these [[users]] may view the library. Empty for no restriction
+	Users []string
+}
+
+// Library is a photo root with the [home] and [imageResizing] defaults resolved
+type Library struct {
+	// empty for the unnamed library configured by [home]
+	Name               string
+	Path               string
+	MinRefreshInterval int
+	ImageResizing      imageResizing
+	// users allowed to view the library, empty when anyone may
+	Users          []string
+	FollowSymlinks bool
+	AllowedRoots   []string
+}
+
+// EffectiveLibraries returns the configured [[libraries]], or a single unnamed library
+// for [home] when there are none
+func (c Config) EffectiveLibraries() []Library {
+	if len(c.Libraries) == 0 {
+		return []Library{{
+			Path:               c.Home.Path,
+			MinRefreshInterval: c.Home.MinRefreshInterval,
+			ImageResizing:      c.ImageResizing,
+			FollowSymlinks:     c.Home.FollowSymlinks,
+			AllowedRoots:       c.Home.AllowedRoots,
+		}}
+	}
+
+	libraries := make([]Library, 0, len(c.Libraries))
+	for _, l := range c.Libraries {
+		library := Library{
+			Name:               l.Name,
+			Path:               l.Path,
+			MinRefreshInterval: l.MinRefreshInterval,
+			ImageResizing:      c.ImageResizing,
+			Users:              l.Users,
+			FollowSymlinks:     c.Home.FollowSymlinks,
+			AllowedRoots:       c.Home.AllowedRoots,
+		}
+		if library.MinRefreshInterval == 0 {
+			library.MinRefreshInterval = c.Home.MinRefreshInterval
+		}
+		overrideInt(&library.ImageResizing.PreviewWidth, l.PreviewWidth)
+		overrideInt(&library.ImageResizing.PreviewHeight, l.PreviewHeight)
+		overrideInt(&library.ImageResizing.ResizedWidth, l.ResizedWidth)
+		overrideInt(&library.ImageResizing.ResizedHeight, l.ResizedHeight)
+		libraries = append(libraries, library)
+	}
+	return libraries
+}
+
+// Library looks up an effective library by name
+func (c Config) Library(name string) (Library, bool) {
+	for _, library := range c.EffectiveLibraries() {
+		if library.Name == name {
+			return library, true
+		}
+	}
+	return Library{}, false
+}
+
+func overrideInt(value *int, override *int) {
+	if override != nil {
+		*value = *override
+	}
+}
+
+func validateLibraries(libraries []libraryConfig, users []userConfig) []error {
+	var errs []error
+	seen := make(map[string]bool)
+	for i, l := range libraries {
+		field := fmt.Sprintf("libraries[%d]", i)
+		if !libraryNamePattern.MatchString(l.Name) {
+			errs = append(errs, &FieldError{Field: field + ".name", Value: fmt.Sprintf("%q", l.Name), Message: "must only contain letters, numbers, '-' and '_'"})
+		}
+		if seen[l.Name] {
+			errs = append(errs, &FieldError{Field: field + ".name", Value: l.Name, Message: "must be unique"})
+		}
+		seen[l.Name] = true
+		if l.Path == "" {
+			errs = append(errs, &FieldError{Field: field + ".path", Value: `""`, Message: "must be set to the directory containing the library"})
+		}
+		if l.MinRefreshInterval < 0 {
+			errs = append(errs, &FieldError{Field: field + ".minRefreshInterval", Value: l.MinRefreshInterval, Message: "must not be negative, use 0 to inherit from home"})
+		}
+		for name, value := range map[string]*int{
+			"previewWidth":  l.PreviewWidth,
+			"previewHeight": l.PreviewHeight,
+			"resizedWidth":  l.ResizedWidth,
+			"resizedHeight": l.ResizedHeight,
+		} {
+			if value != nil && *value < 0 {
+				errs = append(errs, &FieldError{Field: field + "." + name, Value: *value, Message: "must not be negative, use 0 for no limit"})
+			}
+		}
+		for _, user := range l.Users {
+			if !slices.ContainsFunc(users, func(u userConfig) bool { return u.Name == user }) {
+				errs = append(errs, &FieldError{Field: field + ".users", Value: user, Message: "must only contain users configured in [[users]]"})
+			}
+		}
+	}
+	return errs
+}
+
+// ValidateLibraryPaths checks that the path of every library is an existing directory
+func ValidateLibraryPaths(conf Config) error {
+	var errs []error
+	for _, library := range conf.EffectiveLibraries() {
+		err := validateDirectory(library.Path)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateDirectory(path string) error {
+	s, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("home path does not exist: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat home path '%s': %w", path, err)
+	}
+	if !s.IsDir() {
+		return fmt.Errorf("home path is not a directory: %s", path)
+	}
+	return nil
+}
+
+func cleanLibraryPaths(conf *Config) {
+	if conf.Home.Path != "" {
+		conf.Home.Path = filepath.Clean(conf.Home.Path)
+	}
+	for i, root := range conf.Home.AllowedRoots {
+		if root != "" {
+			conf.Home.AllowedRoots[i] = filepath.Clean(root)
+		}
+	}
+	for i := range conf.Libraries {
+		if conf.Libraries[i].Path != "" {
+			conf.Libraries[i].Path = filepath.Clean(conf.Libraries[i].Path)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package application
+
+import "fotodeck/internal/images"
+
+// NewLoader builds an image loader for a library
+func NewLoader(library Library) images.Loader {
+	loader := images.Loader{}
+	ConfigureLoader(&loader, library)
+	return loader
+}
+
+// ConfigureLoader applies the resize settings of a library to an existing loader, keeping its caches
+func ConfigureLoader(loader *images.Loader, library Library) {
+	loader.OptimisedExtension = library.ImageResizing.ResizedFileExtension
+	loader.PreviewExtension = library.ImageResizing.PreviewFileExtension
+	ResizeLoader(loader, library)
+	loader.FollowSymlinks = library.FollowSymlinks
+	loader.AllowedRoots = library.AllowedRoots
+}
+
+// ResizeLoader only applies the resize dimensions of a library, the settings that can change while
+// a library is running. File extensions and the symlink policy decide which files are originals,
+// so they are left as the loader was created with.
+func ResizeLoader(loader *images.Loader, library Library) {
+	loader.MaxOptimisedDimensions = images.Dimensions{
+		Width:  library.ImageResizing.ResizedWidth,
+		Height: library.ImageResizing.ResizedHeight,
+	}
+	loader.MaxPreviewDimensions = images.Dimensions{
+		Width:  library.ImageResizing.PreviewWidth,
+		Height: library.ImageResizing.PreviewHeight,
+	}
+}
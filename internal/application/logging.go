@@ -0,0 +1,64 @@
+package application
+
+import (
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// LogFormats are the valid values of log.format
+var LogFormats = []string{"text", "json"}
+
+// LogLevel is the parsed log.level, Info if it is invalid
+func (c Config) LogLevel() slog.Level {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(c.Log.Level))
+	if err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// TrustedProxies parses server.trustedProxies, skipping invalid entries
+func (c Config) TrustedProxies() []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(c.Server.TrustedProxies))
+	for _, proxy := range c.Server.TrustedProxies {
+		prefix, err := parsePrefix(proxy)
+		if err == nil {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// parsePrefix accepts a CIDR range, or a single IP as a range of one address
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func validateLogging(conf Config) []error {
+	var errs []error
+	var level slog.Level
+	if level.UnmarshalText([]byte(conf.Log.Level)) != nil {
+		errs = append(errs, &FieldError{Field: "log.level", Value: conf.Log.Level, Message: "must be one of debug, info, warn, error"})
+	}
+	if !slices.Contains(LogFormats, conf.Log.Format) {
+		errs = append(errs, &FieldError{Field: "log.format", Value: conf.Log.Format, Message: "must be one of " + strings.Join(LogFormats, ", ")})
+	}
+	for i, proxy := range conf.Server.TrustedProxies {
+		if _, err := parsePrefix(proxy); err != nil {
+			errs = append(errs, &FieldError{Field: fmt.Sprintf("server.trustedProxies[%d]", i), Value: proxy, Message: "must be an IP address or CIDR range"})
+		}
+	}
+	return errs
+}
@@ -0,0 +1,277 @@
+package application
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/samber/lo"
+)
+
+const EnvPrefix = "FOTODECK_"
+
+// Overrides hold option values keyed by their dotted config key, e.g. "home.path".
+// Env values are applied over the config file, then Flags over those.
+type Overrides struct {
+	Env   map[string]string
+	Flags map[string]string
+}
+
+// option is a single settable config value
+type option struct {
+	// dotted key as used in the TOML file, e.g. "imageResizing.previewWidth"
+	Key   string
+	Env   string
+	field reflect.Value
+	// tagged `secret:"true"`, never printed
+	secret bool
+}
+
+// options lists every option of conf, in declaration order. Only the options of struct sections
+// are listed: the [[libraries]] and [[users]] arrays can only be set in the config file.
+func options(conf *Config) []option {
+	opts := make([]option, 0)
+	sections := reflect.ValueOf(conf).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		sectionName := sections.Type().Field(i).Name
+		section := sections.Field(i)
+		if section.Kind() != reflect.Struct {
+			continue
+		}
+		for j := 0; j < section.NumField(); j++ {
+			field := section.Type().Field(j)
+			opts = append(opts, option{
+				Key:    lowerCamel(sectionName) + "." + lowerCamel(field.Name),
+				Env:    EnvPrefix + upperSnake(sectionName) + "_" + upperSnake(field.Name),
+				field:  section.Field(j),
+				secret: field.Tag.Get("secret") == "true",
+			})
+		}
+	}
+	return opts
+}
+
+// EnvOverrides picks the FOTODECK_* variables out of environ (as returned by os.Environ).
+// Variables with the prefix that don't match an option are returned as warnings.
+func EnvOverrides(environ []string) (map[string]string, []string) {
+	var conf Config
+	byEnv := make(map[string]string)
+	for _, opt := range options(&conf) {
+		byEnv[opt.Env] = opt.Key
+	}
+
+	values := make(map[string]string)
+	warnings := make([]string, 0)
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, EnvPrefix) || name == EnvPrefix+"CONFIG" {
+			continue
+		}
+		key, ok := byEnv[name]
+		if !ok && (strings.HasPrefix(name, EnvPrefix+"LIBRARIES_") || strings.HasPrefix(name, EnvPrefix+"USERS_")) {
+			warnings = append(warnings, fmt.Sprintf("environment variable '%s' will be ignored, [[libraries]] and [[users]] can only be set in the config file", name))
+			continue
+		}
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("unknown environment variable '%s' will be ignored", name))
+			continue
+		}
+		values[key] = value
+	}
+	return values, warnings
+}
+
+// RegisterFlags adds a flag named after the dotted key of every option to fs.
+// Values of flags given on the command line are collected into the returned map.
+func RegisterFlags(fs *flag.FlagSet) map[string]string {
+	values := make(map[string]string)
+	defaults := DefaultConfig()
+	for _, opt := range options(&defaults) {
+		key := opt.Key
+		usage := fmt.Sprintf("override %s (env %s, default %v)", key, opt.Env, opt.field.Interface())
+		fs.Func(key, usage, func(value string) error {
+			values[key] = value
+			return nil
+		})
+	}
+	return values
+}
+
+// applyOverrides sets each option in values on conf. source names where the values came from for errors.
+func applyOverrides(conf *Config, values map[string]string, source string) error {
+	for _, opt := range options(conf) {
+		value, ok := values[opt.Key]
+		if !ok {
+			continue
+		}
+		err := setOption(opt.field, value)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", source, opt.Key, err)
+		}
+	}
+	return nil
+}
+
+func setOption(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("'%s' is not an integer", value)
+		}
+		field.SetInt(int64(v))
+	case reflect.Bool:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a boolean", value)
+		}
+		field.SetBool(v)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("can't be set from a string")
+		}
+		// comma separated list
+		values := make([]string, 0)
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("can't be set from a string")
+	}
+	return nil
+}
+
+// CopyOptions sets the options of dst named by keys to their values in src. Keys that aren't
+// options, such as "libraries", are ignored.
+func CopyOptions(dst *Config, src Config, keys []string) {
+	optsSrc := options(&src)
+	for i, opt := range options(dst) {
+		if slices.Contains(keys, opt.Key) {
+			opt.field.Set(optsSrc[i].field)
+		}
+	}
+}
+
+// ChangedKeys lists the dotted keys of options that differ between a and b
+func ChangedKeys(a Config, b Config) []string {
+	changed := make([]string, 0)
+	optsB := options(&b)
+	for i, opt := range options(&a) {
+		if !reflect.DeepEqual(opt.field.Interface(), optsB[i].field.Interface()) {
+			changed = append(changed, opt.Key)
+		}
+	}
+	if !reflect.DeepEqual(a.Libraries, b.Libraries) {
+		changed = append(changed, "libraries")
+	}
+	if !reflect.DeepEqual(a.Users, b.Users) {
+		changed = append(changed, "users")
+	}
+	return changed
+}
+
+// Redacted is printed by PrintConfig in place of passwords and secrets
+const Redacted = "<redacted>"
+
+// PrintConfig writes conf to w in the config file format. Password hashes and options tagged
+// `secret:"true"` are replaced by Redacted.
+func PrintConfig(w io.Writer, conf Config) error {
+	section := ""
+	for _, opt := range options(&conf) {
+		name, key, _ := strings.Cut(opt.Key, ".")
+		if name != section {
+			if section != "" {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "[%s]\n", name)
+			section = name
+		}
+
+		var err error
+		switch opt.field.Kind() {
+		case reflect.String:
+			value := opt.field.String()
+			if opt.secret && value != "" {
+				value = Redacted
+			}
+			_, err = fmt.Fprintf(w, "%s = %s\n", key, strconv.Quote(value))
+		case reflect.Slice:
+			_, err = fmt.Fprintf(w, "%s = %s\n", key, quoteList(opt.field.Interface().([]string)))
+		default:
+			_, err = fmt.Fprintf(w, "%s = %v\n", key, opt.field.Interface())
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, library := range conf.Libraries {
+		_, err := fmt.Fprintf(w, "\n[[libraries]]\nname = %s\npath = %s\n", strconv.Quote(library.Name), strconv.Quote(library.Path))
+		if err != nil {
+			return err
+		}
+		if library.MinRefreshInterval != 0 {
+			fmt.Fprintf(w, "minRefreshInterval = %d\n", library.MinRefreshInterval)
+		}
+		for _, override := range []struct {
+			key   string
+			value *int
+		}{
+			{"previewWidth", library.PreviewWidth},
+			{"previewHeight", library.PreviewHeight},
+			{"resizedWidth", library.ResizedWidth},
+			{"resizedHeight", library.ResizedHeight},
+		} {
+			if override.value != nil {
+				fmt.Fprintf(w, "%s = %d\n", override.key, *override.value)
+			}
+		}
+		if len(library.Users) > 0 {
+			fmt.Fprintf(w, "users = %s\n", quoteList(library.Users))
+		}
+	}
+
+	for _, user := range conf.Users {
+		// the output ends up in bug reports, where hashes could be cracked offline
+		_, err := fmt.Fprintf(w, "\n[[users]]\nname = %s\npasswordHash = %s\n", strconv.Quote(user.Name), strconv.Quote(Redacted))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quoteList formats values as a TOML array of strings
+func quoteList(values []string) string {
+	return "[" + strings.Join(lo.Map(values, func(v string, _ int) string {
+		return strconv.Quote(v)
+	}), ", ") + "]"
+}
+
+func lowerCamel(s string) string {
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+// upperSnake converts a Go field name to an environment variable segment, e.g. MinRefreshInterval -> MIN_REFRESH_INTERVAL
+func upperSnake(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if i > 0 && unicode.IsUpper(r) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
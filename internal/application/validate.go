@@ -0,0 +1,130 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"fotodeck/internal/geo"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// SortOrders are the valid values of gallery.sort
+var SortOrders = []string{"random", "name", "name-desc"}
+
+// SlideshowTransitions are the valid values of slideshow.transition
+var SlideshowTransitions = []string{"fade", "slide", "none"}
+
+// shortest sharing.secret accepted, long enough that share links can't be forged
+const minSharingSecretLength = 32
+
+// FieldError describes an invalid value for a single config option
+type FieldError struct {
+	Field   string
+	Value   any
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s (got %v)", e.Field, e.Message, e.Value)
+}
+
+// Validate checks every option of conf, returning all invalid fields joined into one error
+func Validate(conf Config) error {
+	var errs []error
+	check := func(ok bool, field string, value any, message string) {
+		if !ok {
+			errs = append(errs, &FieldError{Field: field, Value: value, Message: message})
+		}
+	}
+
+	if len(conf.Libraries) == 0 {
+		check(conf.Home.Path != "", "home.path", `""`, "must be set to the directory containing your photos, or [[libraries]] configured")
+	}
+	for i, root := range conf.Home.AllowedRoots {
+		check(filepath.IsAbs(root), fmt.Sprintf("home.allowedRoots[%d]", i), fmt.Sprintf("%q", root), "must be an absolute path")
+	}
+	check(conf.Home.MinRefreshInterval > 0, "home.minRefreshInterval", conf.Home.MinRefreshInterval, "must be at least 1 second")
+
+	check(slices.Contains(SortOrders, conf.Gallery.Sort), "gallery.sort", conf.Gallery.Sort, "must be one of "+strings.Join(SortOrders, ", "))
+	check(conf.Gallery.PageSize >= 0, "gallery.pageSize", conf.Gallery.PageSize, "must not be negative, use 0 for a single page")
+
+	check(conf.Server.ListenAddr != "", "server.listenAddr", conf.Server.ListenAddr, "must be set, e.g. ':8080'")
+	check(conf.Server.ReadTimeout >= 0, "server.readTimeout", conf.Server.ReadTimeout, "must not be negative, use 0 for no timeout")
+	check(conf.Server.ReadHeaderTimeout >= 0, "server.readHeaderTimeout", conf.Server.ReadHeaderTimeout, "must not be negative, use 0 for no timeout")
+	check(conf.Server.WriteTimeout >= 0, "server.writeTimeout", conf.Server.WriteTimeout, "must not be negative, use 0 for no timeout")
+	check(conf.Server.IdleTimeout >= 0, "server.idleTimeout", conf.Server.IdleTimeout, "must not be negative, use 0 for no timeout")
+	errs = append(errs, validateLogging(conf)...)
+	check(conf.Server.MaxHeaderBytes >= 1024, "server.maxHeaderBytes", conf.Server.MaxHeaderBytes, "must be at least 1024")
+	check(conf.Server.MaxBodyBytes >= 1024, "server.maxBodyBytes", conf.Server.MaxBodyBytes, "must be at least 1024")
+	check((conf.Server.TlsCertFile == "") == (conf.Server.TlsKeyFile == ""), "server.tlsKeyFile", conf.Server.TlsKeyFile, "must be set together with server.tlsCertFile")
+
+	resizing := conf.ImageResizing
+	check(resizing.ResizedWidth >= 0, "imageResizing.resizedWidth", resizing.ResizedWidth, "must not be negative, use 0 for no limit")
+	check(resizing.ResizedHeight >= 0, "imageResizing.resizedHeight", resizing.ResizedHeight, "must not be negative, use 0 for no limit")
+	check(resizing.PreviewWidth >= 0, "imageResizing.previewWidth", resizing.PreviewWidth, "must not be negative, use 0 for no limit")
+	check(resizing.PreviewHeight >= 0, "imageResizing.previewHeight", resizing.PreviewHeight, "must not be negative, use 0 for no limit")
+	errs = append(errs, validateExtension("imageResizing.resizedFileExtension", resizing.ResizedFileExtension)...)
+	errs = append(errs, validateExtension("imageResizing.previewFileExtension", resizing.PreviewFileExtension)...)
+	check(!strings.EqualFold(resizing.ResizedFileExtension, resizing.PreviewFileExtension),
+		"imageResizing.previewFileExtension", resizing.PreviewFileExtension, "must differ from resizedFileExtension")
+
+	if conf.RateLimit.Enabled {
+		check(conf.RateLimit.RequestsPerSecond > 0, "rateLimit.requestsPerSecond", conf.RateLimit.RequestsPerSecond, "must be at least 1")
+		check(conf.RateLimit.Burst > 0, "rateLimit.burst", conf.RateLimit.Burst, "must be at least 1")
+	}
+	check(conf.RateLimit.MaxConcurrentFullSize >= 0, "rateLimit.maxConcurrentFullSize", conf.RateLimit.MaxConcurrentFullSize, "must not be negative, use 0 for no limit")
+
+	check(!conf.Web.Dev || conf.Web.Dir != "", "web.dir", `""`, "must be set in dev mode")
+
+	check(conf.Slideshow.Interval >= 1, "slideshow.interval", conf.Slideshow.Interval, "must be at least 1 second")
+	check(slices.Contains(SlideshowTransitions, conf.Slideshow.Transition), "slideshow.transition", conf.Slideshow.Transition,
+		"must be one of "+strings.Join(SlideshowTransitions, ", "))
+	libraryNames := lo.Map(conf.Libraries, func(library libraryConfig, _ int) string { return library.Name })
+	check(conf.Slideshow.Album == "" || slices.Contains(libraryNames, conf.Slideshow.Album), "slideshow.album", conf.Slideshow.Album,
+		"must be the name of one of the [[libraries]], or empty for the whole gallery")
+
+	for _, placeholder := range []string{"{z}", "{x}", "{y}"} {
+		check(strings.Contains(conf.Map.TileUrl, placeholder), "map.tileUrl", conf.Map.TileUrl, "must contain "+placeholder)
+	}
+	check(conf.Map.MaxZoom >= 0 && conf.Map.MaxZoom <= geo.MaxZoom, "map.maxZoom", conf.Map.MaxZoom, fmt.Sprintf("must be between 0 and %d", geo.MaxZoom))
+
+	check(conf.Similarity.Threshold >= 0 && conf.Similarity.Threshold <= 64, "similarity.threshold", conf.Similarity.Threshold, "must be between 0 and 64")
+
+	check(conf.Auth.SessionLifetime > 0, "auth.sessionLifetime", conf.Auth.SessionLifetime, "must be at least 1 second")
+	check(!conf.Auth.Required || len(conf.Users) > 0, "auth.required", conf.Auth.Required, "requires at least one [[users]] entry")
+	errs = append(errs, validateUsers(conf.Users)...)
+
+	// don't echo the secret
+	check(conf.Sharing.Secret == "" || len(conf.Sharing.Secret) >= minSharingSecretLength, "sharing.secret", "<hidden>",
+		fmt.Sprintf("must be at least %d characters, or empty to disable sharing", minSharingSecretLength))
+	check(conf.Sharing.DefaultExpiry > 0, "sharing.defaultExpiry", conf.Sharing.DefaultExpiry, "must be at least 1 second")
+	errs = append(errs, validateLibraries(conf.Libraries, conf.Users)...)
+
+	return errors.Join(errs...)
+}
+
+// derivative file extensions are matched by substring against every file name,
+// so they must be non-empty and must not contain path or extension separators
+func validateExtension(field string, ext string) []error {
+	if ext == "" {
+		return []error{&FieldError{Field: field, Value: `""`, Message: "must not be empty"}}
+	}
+	if strings.ContainsAny(ext, `./\`) {
+		return []error{&FieldError{Field: field, Value: ext, Message: "must not contain '.', '/' or '\\'"}}
+	}
+	if isFiletypeExtension(ext) {
+		return []error{&FieldError{Field: field, Value: ext, Message: "must not be an image file extension"}}
+	}
+	return nil
+}
+
+func isFiletypeExtension(ext string) bool {
+	switch strings.ToLower(ext) {
+	case "png", "jpeg", "jpg", "svg", "gif":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+// Package geo groups geotagged photos into clusters for showing them on a map
+package geo
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxZoom is the deepest zoom level of web map tiles
+const MaxZoom = 22
+
+// cellsPerTile splits each 256 pixel map tile into a grid of cells of 64 pixels, so markers of
+// neighbouring clusters don't overlap
+const cellsPerTile = 4
+
+// latitudes beyond this can't be shown on Web Mercator maps
+const maxMercatorLatitude = 85.05112878
+
+// Point is a photo and where it was taken
+type Point struct {
+	ID        string
+	Latitude  float64
+	Longitude float64
+}
+
+// BBox is the area a map shows, in degrees. West is greater than East when it crosses the antimeridian.
+type BBox struct {
+	West  float64
+	South float64
+	East  float64
+	North float64
+}
+
+// World covers every point
+var World = BBox{West: -180, South: -90, East: 180, North: 90}
+
+// ParseBBox reads a bounding box as west,south,east,north, the order used by GeoJSON
+func ParseBBox(s string) (BBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return BBox{}, errors.New("bbox must be west,south,east,north")
+	}
+	var values [4]float64
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(value) {
+			return BBox{}, errors.New("bbox must be four numbers")
+		}
+		values[i] = value
+	}
+	bbox := BBox{West: values[0], South: values[1], East: values[2], North: values[3]}
+	if bbox.South > bbox.North || math.Abs(bbox.South) > 90 || math.Abs(bbox.North) > 90 {
+		return BBox{}, errors.New("bbox latitudes must be between -90 and 90, south first")
+	}
+	if math.Abs(bbox.West) > 180 || math.Abs(bbox.East) > 180 {
+		return BBox{}, errors.New("bbox longitudes must be between -180 and 180")
+	}
+	return bbox, nil
+}
+
+// Contains is true when the point is within the box
+func (b BBox) Contains(latitude float64, longitude float64) bool {
+	if latitude < b.South || latitude > b.North {
+		return false
+	}
+	if b.West <= b.East {
+		return longitude >= b.West && longitude <= b.East
+	}
+	return longitude >= b.West || longitude <= b.East
+}
+
+// Cluster is a group of points close together at a zoom level
+type Cluster struct {
+	// mean position of the points
+	Latitude  float64
+	Longitude float64
+	// IDs of the points, in the order they were given
+	IDs []string
+}
+
+// Clusters groups the points within bbox by the grid cell of the map they fall in at zoom.
+// Clusters keep the order of their first point, so sorting points by relevance puts the most
+// relevant photo first in each cluster.
+func Clusters(points []Point, bbox BBox, zoom int) []Cluster {
+	zoom = min(max(zoom, 0), MaxZoom)
+	cells := make(map[[2]int]int)
+	clusters := make([]Cluster, 0)
+	for _, point := range points {
+		if !bbox.Contains(point.Latitude, point.Longitude) {
+			continue
+		}
+		cell := gridCell(point.Latitude, point.Longitude, zoom)
+		i, ok := cells[cell]
+		if !ok {
+			i = len(clusters)
+			cells[cell] = i
+			clusters = append(clusters, Cluster{})
+		}
+		clusters[i].Latitude += point.Latitude
+		clusters[i].Longitude += point.Longitude
+		clusters[i].IDs = append(clusters[i].IDs, point.ID)
+	}
+	for i := range clusters {
+		clusters[i].Latitude /= float64(len(clusters[i].IDs))
+		clusters[i].Longitude /= float64(len(clusters[i].IDs))
+	}
+	return clusters
+}
+
+// gridCell is the cell of the Web Mercator grid at zoom the point falls in
+func gridCell(latitude float64, longitude float64, zoom int) [2]int {
+	size := float64(int(1)<<zoom) * cellsPerTile
+	latitude = min(max(latitude, -maxMercatorLatitude), maxMercatorLatitude) * math.Pi / 180
+	x := (longitude + 180) / 360 * size
+	y := (1 - math.Log(math.Tan(latitude)+1/math.Cos(latitude))/math.Pi) / 2 * size
+	return [2]int{min(int(x), int(size)-1), min(int(y), int(size)-1)}
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AccessLog logs one record per request with its status, size and latency.
+// Successful requests for images and static files are logged at Debug, as a page load makes many of them,
+// as are health checks, which orchestrators make every few seconds.
+type AccessLog struct {
+	Logger *slog.Logger
+}
+
+// statusRecorder captures the status code and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += int64(n)
+	return n, err
+}
+
+// statusCode is the status sent, 200 when the handler never wrote a header
+func (s *statusRecorder) statusCode() int {
+	if s.status == 0 {
+		return http.StatusOK
+	}
+	return s.status
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+func (a *AccessLog) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		status := recorder.statusCode()
+		level := slog.LevelInfo
+		if status < http.StatusBadRequest && isQuietPath(r.URL.Path) {
+			level = slog.LevelDebug
+		}
+		a.Logger.LogAttrs(context.Background(), level, "request",
+			slog.String("remoteAddr", clientIP(r)),
+			slog.String("method", r.Method),
+			slog.String("url", r.URL.RequestURI()),
+			slog.String("proto", r.Proto),
+			slog.Int("status", status),
+			slog.Int64("bytes", recorder.bytes),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("userAgent", r.UserAgent()),
+		)
+	})
+}
+
+// frequently requested paths, not worth logging at Info when successful
+func isQuietPath(path string) bool {
+	return path == "/healthz" || path == "/readyz" || strings.HasPrefix(path, "/img/") || strings.HasPrefix(path, "/public/") ||
+		(strings.HasPrefix(path, "/share/") && strings.Contains(path, "/img/"))
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"fotodeck/internal/images"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+type ApiHandler struct {
+	FileHolder          *FileHolder
+	Settings            *SiteSettings
+	SimilarityThreshold int
+	// nil when authentication is disabled
+	Auth *Auth
+}
+
+func (ah *ApiHandler) Duplicates(w http.ResponseWriter, r *http.Request) {
+	ah.FileHolder.Mu.RLock()
+	groups := images.FindDuplicates(ah.FileHolder.Entries)
+	ah.FileHolder.Mu.RUnlock()
+
+	// only list copies the user may view
+	visible := make([]images.DuplicateGroup, 0, len(groups))
+	for _, group := range groups {
+		group.Files = ah.Auth.Visible(r, group.Files)
+		if len(group.Files) > 1 {
+			visible = append(visible, group)
+		}
+	}
+	writeJson(w, http.StatusOK, visible)
+}
+
+// Similar lists photos that look like {id}. The distance query param overrides the configured threshold.
+func (ah *ApiHandler) Similar(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	threshold := ah.SimilarityThreshold
+	if param := r.URL.Query().Get("distance"); param != "" {
+		distance, err := strconv.Atoi(param)
+		if err != nil || distance < 0 || distance > 64 {
+			writeJson(w, http.StatusBadRequest, map[string]string{"error": "distance must be an integer between 0 and 64"})
+			return
+		}
+		threshold = distance
+	}
+
+	ah.FileHolder.Mu.RLock()
+	_, ok := ah.FileHolder.Entries[id]
+	similar := images.FindSimilar(ah.FileHolder.Entries, id, threshold)
+	ah.FileHolder.Mu.RUnlock()
+
+	library, _ := images.SplitLibraryID(id)
+	if !ok || !ah.Auth.CanView(r, library) {
+		writeJson(w, http.StatusNotFound, map[string]string{"error": "photo not found"})
+		return
+	}
+	similar = slices.DeleteFunc(similar, func(s images.SimilarImage) bool {
+		library, _ := images.SplitLibraryID(s.ID)
+		return !ah.Auth.CanView(r, library)
+	})
+	writeJson(w, http.StatusOK, similar)
+}
+
+func writeJson(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		slog.Error("Failed to encode json response", "error", err)
+	}
+}
@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fotodeck/internal/images"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const sessionCookie = "fotodeck_session"
+
+// compared against when a user doesn't exist, so unknown users take as long to reject as wrong passwords
+var dummyPasswordHash = []byte("$2a$10$OdYhsUrq7GvhSC7CPCoD7e/atGY9aK.lokxXlVVc8hoY3ARhWguO2")
+
+type userContextKey struct{}
+
+type session struct {
+	user    string
+	expires time.Time
+}
+
+// Auth authenticates requests with HTTP basic auth or a session cookie from the login page,
+// and decides which libraries a user may view. A nil *Auth allows everything.
+type Auth struct {
+	// bcrypt password hashes keyed by user name
+	Users map[string]string
+	// users allowed to view each restricted library. Libraries not listed are open to anyone let in
+	LibraryUsers map[string][]string
+	// require a login for every page, not only restricted libraries
+	Required        bool
+	SessionLifetime time.Duration
+	Templates       *Templates
+
+	mu       sync.Mutex
+	sessions map[string]session
+	// sha256 of passwords that already matched their bcrypt hash, so basic auth
+	// doesn't pay for bcrypt on every image request
+	verified map[string][sha256.Size]byte
+}
+
+type LoginTemplate struct {
+	Next  string
+	Error string
+}
+
+// UserFromRequest returns the authenticated user, or "" for anonymous requests
+func UserFromRequest(r *http.Request) string {
+	user, _ := r.Context().Value(userContextKey{}).(string)
+	return user
+}
+
+// Middleware identifies the user of each request, challenging anonymous requests when a login is required
+func (a *Auth) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := ""
+		if name, password, ok := r.BasicAuth(); ok {
+			if !a.checkPassword(name, password) {
+				slog.Warn("failed basic auth", "user", name, "remoteAddr", clientIP(r))
+				a.challenge(w, r)
+				return
+			}
+			user = name
+		} else if cookie, err := r.Cookie(sessionCookie); err == nil {
+			user = a.sessionUser(cookie.Value)
+		}
+
+		if user == "" && a.Required && !isPublicPath(r.URL.Path) {
+			a.challenge(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userContextKey{}, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// CanView is true when the user of r may view photos in library
+func (a *Auth) CanView(r *http.Request, library string) bool {
+	if a == nil {
+		return true
+	}
+	users, restricted := a.LibraryUsers[library]
+	if !restricted {
+		return true
+	}
+	return slices.Contains(users, UserFromRequest(r))
+}
+
+// Visible filters photo IDs down to those in libraries the user of r may view
+func (a *Auth) Visible(r *http.Request, ids []string) []string {
+	if a == nil {
+		return ids
+	}
+	return slices.DeleteFunc(slices.Clone(ids), func(id string) bool {
+		library, _ := images.SplitLibraryID(id)
+		return !a.CanView(r, library)
+	})
+}
+
+// challenge asks for credentials, sending browsers to the login page
+func (a *Auth) challenge(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && !strings.HasPrefix(r.URL.Path, "/api/") && !strings.HasPrefix(r.URL.Path, "/img/") {
+		http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+		return
+	}
+	w.Header().Set("WWW-Authenticate", `Basic realm="fotodeck", charset="UTF-8"`)
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+// Login shows the login form, and on submit starts a session for valid credentials
+func (a *Auth) Login(w http.ResponseWriter, r *http.Request) {
+	next := safeRedirect(r.FormValue("next"))
+	if r.Method != http.MethodPost {
+		a.Templates.Render(w, http.StatusOK, "login.html", LoginTemplate{Next: next})
+		return
+	}
+
+	name := r.PostFormValue("user")
+	if !a.checkPassword(name, r.PostFormValue("password")) {
+		slog.Warn("failed login", "user", name, "remoteAddr", clientIP(r))
+		a.Templates.Render(w, http.StatusUnauthorized, "login.html", LoginTemplate{Next: next, Error: "Incorrect user name or password"})
+		return
+	}
+
+	token := a.startSession(name)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(a.SessionLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	slog.Info("user logged in", "user", name, "remoteAddr", clientIP(r))
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// Logout ends the session of the request
+func (a *Auth) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		a.mu.Lock()
+		delete(a.sessions, cookie.Value)
+		a.mu.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (a *Auth) checkPassword(name string, password string) bool {
+	hash, ok := a.Users[name]
+	if !ok {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(password))
+	a.mu.Lock()
+	verified, ok := a.verified[name]
+	a.mu.Unlock()
+	if ok && subtle.ConstantTimeCompare(verified[:], sum[:]) == 1 {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.verified == nil {
+		a.verified = make(map[string][sha256.Size]byte)
+	}
+	a.verified[name] = sum
+	return true
+}
+
+func (a *Auth) startSession(user string) string {
+	token := make([]byte, 32)
+	_, _ = rand.Read(token) // never returns an error
+	id := hex.EncodeToString(token)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.sessions == nil {
+		a.sessions = make(map[string]session)
+	}
+	now := time.Now()
+	for id, s := range a.sessions {
+		if now.After(s.expires) {
+			delete(a.sessions, id)
+		}
+	}
+	a.sessions[id] = session{user: user, expires: now.Add(a.SessionLifetime)}
+	return id
+}
+
+// sessionUser returns the user of an unexpired session, or ""
+func (a *Auth) sessionUser(id string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s, ok := a.sessions[id]
+	if !ok || time.Now().After(s.expires) {
+		return ""
+	}
+	return s.user
+}
+
+// pages reachable without logging in. Share links carry their own token
+func isPublicPath(path string) bool {
+	return path == "/login" || path == "/logout" || path == "/healthz" || path == "/readyz" ||
+		strings.HasPrefix(path, "/public/") || strings.HasPrefix(path, "/share/")
+}
+
+// safeRedirect only allows redirects to paths on this site
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type clientIPContextKey struct{}
+
+// ClientIP finds the address of the client, trusting X-Forwarded-For only when
+// the request comes from one of TrustedProxies
+type ClientIP struct {
+	TrustedProxies []netip.Prefix
+}
+
+func (c *ClientIP) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), clientIPContextKey{}, c.resolve(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// resolve walks X-Forwarded-For from the nearest hop back, stopping at the first address that isn't a trusted proxy
+func (c *ClientIP) resolve(r *http.Request) string {
+	ip := remoteIP(r)
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || !c.trusted(addr) {
+		return ip
+	}
+
+	hops := make([]string, 0)
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// can't trust anything further back than a malformed entry
+			break
+		}
+		ip = hop.Unmap().String()
+		if !c.trusted(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+func (c *ClientIP) trusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range c.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP is the address the request came from, as resolved by the ClientIP middleware
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPContextKey{}).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+// remoteIP is the address of the connection, without the port
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
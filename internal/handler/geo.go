@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"fotodeck/internal/geo"
+	"net/http"
+	"strconv"
+)
+
+// MapSettings configure the map page
+type MapSettings struct {
+	// tile URL with {z}, {x} and {y} placeholders
+	TileURL     string
+	Attribution string
+	MaxZoom     int
+}
+
+// MapTemplate is the data map.html is rendered with. Themes depend on these fields,
+// so they are documented in web/README.md and should only be added to.
+type MapTemplate struct {
+	Title     string
+	SiteTitle string
+	// tile URL with {z}, {x} and {y} placeholders
+	TileURL     string
+	Attribution string
+	MaxZoom     int
+	User        string
+	CanLogin    bool
+}
+
+// GeoJSON is a FeatureCollection of photo clusters
+type GeoJSON struct {
+	Type     string       `json:"type"`
+	Features []GeoFeature `json:"features"`
+}
+
+type GeoFeature struct {
+	Type       string        `json:"type"`
+	Geometry   GeoPoint      `json:"geometry"`
+	Properties GeoProperties `json:"properties"`
+}
+
+type GeoPoint struct {
+	Type string `json:"type"`
+	// longitude first, as GeoJSON has it
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type GeoProperties struct {
+	// photos in the cluster
+	Count int `json:"count"`
+	// newest photo of the cluster
+	Photo Photo `json:"photo"`
+}
+
+// Geo clusters the geotagged photos within the bbox query param, given as west,south,east,north,
+// for the map zoom level in the zoom param. Without a bbox every photo is clustered.
+func (ah *ApiHandler) Geo(w http.ResponseWriter, r *http.Request) {
+	bbox := geo.World
+	if param := r.URL.Query().Get("bbox"); param != "" {
+		var err error
+		if bbox, err = geo.ParseBBox(param); err != nil {
+			writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	zoom, err := intParam(r, "zoom", 0)
+	if err != nil || zoom < 0 || zoom > geo.MaxZoom {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "zoom must be an integer between 0 and " + strconv.Itoa(geo.MaxZoom)})
+		return
+	}
+
+	points := make([]geo.Point, 0)
+	for _, photo := range ah.FileHolder.galleryPhotos(func(library string) bool {
+		return ah.Auth.CanView(r, library)
+	}) {
+		if photo.location != nil {
+			points = append(points, geo.Point{ID: photo.id, Latitude: photo.location.Latitude, Longitude: photo.location.Longitude})
+		}
+	}
+
+	collection := GeoJSON{Type: "FeatureCollection", Features: make([]GeoFeature, 0)}
+	for _, cluster := range geo.Clusters(points, bbox, zoom) {
+		collection.Features = append(collection.Features, GeoFeature{
+			Type: "Feature",
+			Geometry: GeoPoint{
+				Type:        "Point",
+				Coordinates: [2]float64{cluster.Longitude, cluster.Latitude},
+			},
+			Properties: GeoProperties{
+				Count: len(cluster.IDs),
+				Photo: newPhotos(ah.FileHolder, cluster.IDs[:1], "/img")[0],
+			},
+		})
+	}
+	writeJson(w, http.StatusOK, collection)
+}
+
+// Map shows the geotagged photos on a map, loading them from /api/geo as it is moved
+func (rh *RootHandler) Map(w http.ResponseWriter, r *http.Request) {
+	siteTitle, _ := rh.Settings.Get()
+	rh.Templates.Render(w, http.StatusOK, "map.html", MapTemplate{
+		Title:       "Map",
+		SiteTitle:   siteTitle,
+		TileURL:     rh.MapSettings.TileURL,
+		Attribution: rh.MapSettings.Attribution,
+		MaxZoom:     rh.MapSettings.MaxZoom,
+		User:        UserFromRequest(r),
+		CanLogin:    rh.Auth != nil,
+	})
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"sync"
+)
+
+const (
+	StatusReady    = "ready"
+	StatusDegraded = "degraded"
+	StatusNotReady = "not ready"
+)
+
+// LibraryHealth is the readiness of a single library
+type LibraryHealth struct {
+	Name string `json:"name"`
+	// the initial load and optimisation has finished
+	Loaded bool `json:"loaded"`
+	// the library path is an accessible directory
+	Accessible bool `json:"accessible"`
+	// changes to the library are being watched
+	Watching     bool   `json:"watching"`
+	WatcherError string `json:"watcherError,omitempty"`
+
+	path string
+}
+
+type ReadinessResponse struct {
+	Status    string          `json:"status"`
+	Libraries []LibraryHealth `json:"libraries"`
+}
+
+// Health tracks the startup state of each library for the health and readiness endpoints
+type Health struct {
+	mu        sync.RWMutex
+	libraries []*LibraryHealth
+}
+
+// AddLibrary registers a library as not yet loaded
+func (h *Health) AddLibrary(name string, path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.libraries = append(h.libraries, &LibraryHealth{Name: name, path: path})
+}
+
+// SetLoaded marks the initial load of a library as finished
+func (h *Health) SetLoaded(name string) {
+	h.update(name, func(l *LibraryHealth) {
+		l.Loaded = true
+	})
+}
+
+// SetWatching records whether changes to a library are watched, and why not
+func (h *Health) SetWatching(name string, watching bool, err error) {
+	h.update(name, func(l *LibraryHealth) {
+		l.Watching = watching
+		l.WatcherError = ""
+		if err != nil {
+			l.WatcherError = err.Error()
+		}
+	})
+}
+
+func (h *Health) update(name string, fn func(l *LibraryHealth)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, l := range h.libraries {
+		if l.Name == name {
+			fn(l)
+		}
+	}
+}
+
+// Healthz reports that the process is alive and serving requests
+func (h *Health) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz reports whether every library is loaded and accessible. Libraries that aren't watched
+// for changes are served but stale, so are reported as degraded while staying ready.
+func (h *Health) Readyz(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	libraries := make([]LibraryHealth, 0, len(h.libraries))
+	for _, l := range h.libraries {
+		libraries = append(libraries, *l)
+	}
+	h.mu.RUnlock()
+
+	resp := ReadinessResponse{Status: StatusReady, Libraries: libraries}
+	for i := range resp.Libraries {
+		l := &resp.Libraries[i]
+		if l.Name == "" {
+			l.Name = "home"
+		}
+		info, err := os.Stat(l.path)
+		l.Accessible = err == nil && info.IsDir()
+
+		switch {
+		case !l.Loaded || !l.Accessible:
+			resp.Status = StatusNotReady
+		case !l.Watching && resp.Status == StatusReady:
+			resp.Status = StatusDegraded
+		}
+	}
+
+	status := http.StatusOK
+	if resp.Status == StatusNotReady {
+		status = http.StatusServiceUnavailable
+	}
+	writeJson(w, status, resp)
+}
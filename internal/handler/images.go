@@ -1,18 +1,21 @@
 package handler
 
 import (
+	"bytes"
 	"fotodeck/internal/images"
 	"log/slog"
 	"net/http"
 )
 
 type ImageHandler struct {
-	FileEntries map[string]images.ImageFile
+	FileHolder *FileHolder
+	// nil when authentication is disabled
+	Auth *Auth
 }
 
 func (ih *ImageHandler) Previews(w http.ResponseWriter, r *http.Request) {
 	requestFile := r.PathValue("id")
-	entry, ok := ih.FileEntries[requestFile]
+	entry, ok := ih.get(r, requestFile)
 	if !ok {
 		w.WriteHeader(http.StatusNotFound)
 		return
@@ -20,12 +23,12 @@ func (ih *ImageHandler) Previews(w http.ResponseWriter, r *http.Request) {
 
 	slog.Debug("", "requestFile", "/img/preview/"+requestFile, "responseFile", entry.GetPreview())
 
-	http.ServeFile(w, r, entry.GetPreview())
+	serveImage(w, r, entry.GetPreview())
 }
 
 func (ih *ImageHandler) Images(w http.ResponseWriter, r *http.Request) {
 	requestFile := r.PathValue("id")
-	entry, ok := ih.FileEntries[requestFile]
+	entry, ok := ih.get(r, requestFile)
 	if !ok {
 		w.WriteHeader(http.StatusNotFound)
 		return
@@ -33,5 +36,43 @@ func (ih *ImageHandler) Images(w http.ResponseWriter, r *http.Request) {
 
 	slog.Debug("", "requestFile", "/img/"+requestFile, "responseFile", entry.GetFullSize())
 
-	http.ServeFile(w, r, entry.GetFullSize())
+	serveImage(w, r, entry.GetFullSize())
+}
+
+// get looks up a photo the user of r may view. IDs are only ever looked up in the catalog,
+// never joined onto a path, so crafted IDs can't reach files outside the libraries
+func (ih *ImageHandler) get(r *http.Request, id string) (images.ImageFile, bool) {
+	library, _ := images.SplitLibraryID(id)
+	if !ih.Auth.CanView(r, library) {
+		return images.ImageFile{}, false
+	}
+	return ih.FileHolder.Get(id)
+}
+
+func serveImage(w http.ResponseWriter, r *http.Request, path string) {
+	if images.IsSvg(path) {
+		serveSvg(w, path)
+		return
+	}
+
+	http.ServeFile(w, r, path)
+}
+
+// serveSvg writes a sanitised copy of the SVG, with a CSP that blocks anything the sanitiser missed
+func serveSvg(w http.ResponseWriter, path string) {
+	var buf bytes.Buffer
+	err := images.SanitiseSvgFile(path, &buf)
+	if err != nil {
+		slog.Error("failed to sanitise svg", "path", path, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", images.SvgContentType)
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_, err = buf.WriteTo(w)
+	if err != nil {
+		slog.Error("failed to write svg", "path", path, "error", err)
+	}
 }
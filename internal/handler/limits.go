@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// idle buckets are refilled completely, so are removed after this long to bound memory
+const bucketIdleTimeout = 10 * time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter is a token bucket per client IP. Each request takes a token; tokens refill
+// at Rate per second up to Burst. Requests without a token get a 429 with Retry-After.
+type RateLimiter struct {
+	Rate  float64
+	Burst int
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r)
+		ok, retryAfter := rl.allow(ip, time.Now())
+		if !ok {
+			slog.Warn("rate limited", "remoteAddr", ip, "url", r.URL)
+			tooManyRequests(w, retryAfter)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allow takes a token from the bucket of ip, or reports how long until one is available
+func (rl *RateLimiter) allow(ip string, now time.Time) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.buckets == nil {
+		rl.buckets = make(map[string]*bucket)
+	}
+	if now.Sub(rl.lastSweep) > bucketIdleTimeout {
+		for key, b := range rl.buckets {
+			if now.Sub(b.last) > bucketIdleTimeout {
+				delete(rl.buckets, key)
+			}
+		}
+		rl.lastSweep = now
+	}
+
+	b, ok := rl.buckets[ip]
+	if !ok {
+		b = &bucket{tokens: float64(rl.Burst), last: now}
+		rl.buckets[ip] = b
+	}
+	b.tokens = math.Min(float64(rl.Burst), b.tokens+now.Sub(b.last).Seconds()*rl.Rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rl.Rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// ConcurrencyLimiter caps the number of requests handled at once, rejecting the rest with a 429
+type ConcurrencyLimiter struct {
+	slots chan struct{}
+}
+
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{slots: make(chan struct{}, max)}
+}
+
+func (cl *ConcurrencyLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case cl.slots <- struct{}{}:
+			defer func() { <-cl.slots }()
+			next.ServeHTTP(w, r)
+		default:
+			slog.Warn("too many concurrent requests", "remoteAddr", clientIP(r), "url", r.URL, "limit", cap(cl.slots))
+			tooManyRequests(w, time.Second)
+		}
+	})
+}
+
+// LimitBodySize fails reads of request bodies larger than max bytes
+func LimitBodySize(max int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, max)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func tooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	// Retry-After is in whole seconds, round up so clients don't retry too early
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+	http.Error(w, "Too many requests", http.StatusTooManyRequests)
+}
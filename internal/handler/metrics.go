@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"fotodeck/internal/metrics"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Instrument records request counts, latency and bytes served for a route.
+// route should be the pattern the handler is registered with, to keep label cardinality bounded.
+func Instrument(route string, next http.Handler) http.Handler {
+	duration := metrics.HttpRequestDuration.WithLabelValues(route)
+	responseBytes := metrics.HttpResponseBytes.WithLabelValues(route)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		duration.Observe(time.Since(start).Seconds())
+		responseBytes.Add(float64(recorder.bytes))
+		metrics.HttpRequests.WithLabelValues(route, r.Method, strconv.Itoa(recorder.statusCode())).Inc()
+	})
+}
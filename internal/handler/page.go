@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"fotodeck/internal/images"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// orderUnchanged keeps photos in the order they were found, e.g. for search results
+const orderUnchanged = ""
+
+// Photo is a photo shown on a page. It prints as its ID, so templates can also build image URLs from it.
+type Photo struct {
+	ID string `json:"id"`
+	// file name within its album
+	Name string `json:"name"`
+	// library the photo is in, empty for the unnamed home library
+	Album      string `json:"album"`
+	PreviewURL string `json:"previewUrl"`
+	URL        string `json:"url"`
+	// from the sidecar files of the photo
+	Caption string   `json:"caption"`
+	Tags    []string `json:"tags"`
+	// stars from 1 to 5, 0 when unrated
+	Rating int `json:"rating"`
+	// from the EXIF of the photo
+	Camera string `json:"camera"`
+	// when the photo was taken, or the file modification time without EXIF
+	Taken time.Time `json:"taken"`
+	// where the photo was taken, nil when it isn't geotagged
+	Location *images.Location `json:"location"`
+}
+
+func (p Photo) String() string {
+	return p.ID
+}
+
+// Page describes the part of a gallery shown when it is split into pages
+type Page struct {
+	// starting from 1
+	Number int
+	Count  int
+	// photos per page, 0 when every photo is on one page
+	Size int
+	// photos across all pages
+	Total int
+	// links to the neighbouring pages, empty on the first and last page
+	PrevURL string
+	NextURL string
+}
+
+// paginate sorts ids and returns the page requested by the page query parameter.
+// When paging a random order the shuffle is seeded by the seed parameter, which the page links carry,
+// so moving between pages doesn't repeat or skip photos.
+func paginate(r *http.Request, ids []string, order string, size int) ([]string, Page) {
+	page := Page{Number: 1, Count: 1, Total: len(ids)}
+	if size <= 0 {
+		sortPhotos(ids, order, rand.Int63()) // #nosec G404 -- secure random not required
+		return ids, page
+	}
+
+	// links keep the other params, such as the search and its filters
+	query := r.URL.Query()
+	seed, err := strconv.ParseInt(r.URL.Query().Get("seed"), 10, 64)
+	if order == "random" {
+		if err != nil {
+			seed = rand.Int63() // #nosec G404 -- secure random not required
+		}
+		query.Set("seed", strconv.FormatInt(seed, 10))
+	}
+	sortPhotos(ids, order, seed)
+
+	page.Size = size
+	page.Count = max(1, (len(ids)+size-1)/size)
+	number, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	page.Number = min(max(number, 1), page.Count)
+	link := func(number int) string {
+		query.Set("page", strconv.Itoa(number))
+		return "?" + query.Encode()
+	}
+	if page.Number > 1 {
+		page.PrevURL = link(page.Number - 1)
+	}
+	if page.Number < page.Count {
+		page.NextURL = link(page.Number + 1)
+	}
+
+	start := (page.Number - 1) * size
+	return ids[start:min(start+size, len(ids))], page
+}
+
+// newPhotos describes the photos of ids, with image URLs under imagePrefix
+func newPhotos(fileHolder *FileHolder, ids []string, imagePrefix string) []Photo {
+	return lo.Map(ids, func(id string, _ int) Photo {
+		album, name := images.SplitLibraryID(id)
+		escaped := url.PathEscape(id)
+		photo := Photo{
+			ID:         id,
+			Name:       name,
+			Album:      album,
+			PreviewURL: imagePrefix + "/preview/" + escaped,
+			URL:        imagePrefix + "/" + escaped,
+		}
+		if entry, ok := fileHolder.Get(id); ok {
+			metadata := entry.Metadata()
+			photo.Caption = metadata.Caption
+			// never null in JSON
+			photo.Tags = append([]string{}, metadata.Tags...)
+			photo.Rating = metadata.Rating
+			photo.Camera = metadata.Camera
+			photo.Taken = metadata.Taken
+			photo.Location = metadata.Location
+		}
+		return photo
+	})
+}
+
+// sortPhotos orders f in place. The random order is a shuffle seeded by seed. Other orders,
+// such as orderUnchanged, keep f as it is
+func sortPhotos(f []string, order string, seed int64) {
+	switch order {
+	case "name":
+		slices.Sort(f)
+	case "name-desc":
+		slices.Sort(f)
+		slices.Reverse(f)
+	case "random":
+		// sort first, as the shuffle only repeats for the same seed when the input order does
+		slices.Sort(f)
+		rng := rand.New(rand.NewSource(seed)) // #nosec G404 -- secure random not required
+		rng.Shuffle(len(f), func(i int, j int) {
+			f[i], f[j] = f[j], f[i]
+		})
+	}
+}
@@ -1,17 +1,31 @@
 package handler
 
 import (
-	"log/slog"
-	"math/rand"
+	"cmp"
+	"fotodeck/internal/images"
+	"fotodeck/internal/metrics"
+	"fotodeck/internal/search"
 	"net/http"
+	"path"
+	"slices"
+	"strings"
 	"sync"
-	"text/template"
 	"time"
+
+	"github.com/samber/lo"
 )
 
 type FileHolder struct {
-	Mu    sync.RWMutex
-	Files []string
+	Mu      sync.RWMutex
+	Files   []string
+	Entries map[string]images.ImageFile
+
+	// entries of each library keyed by their name within the library
+	libraries map[string]map[string]images.ImageFile
+	// sidecar metadata of each album, by images.AlbumName
+	albums map[string]images.Album
+	// search index of Files, rebuilt whenever they or the albums change
+	index *search.Index
 }
 
 // helper method to set files. Handles locking
@@ -22,47 +36,462 @@ func (f *FileHolder) Set(files []string) {
 	f.Files = files
 }
 
+// helper method to set the full catalog when there is a single unnamed library.
+// Handles locking
+func (f *FileHolder) SetEntries(entries map[string]images.ImageFile, hideDuplicates bool) {
+	f.SetLibrary("", entries, hideDuplicates)
+}
+
+// helper method to replace the entries of one library and rebuild the merged catalog.
+// Files shown in the grid have duplicates removed when hideDuplicates is set. Handles locking
+func (f *FileHolder) SetLibrary(name string, entries map[string]images.ImageFile, hideDuplicates bool) {
+	f.Mu.Lock()
+	defer f.Mu.Unlock()
+
+	if f.libraries == nil {
+		f.libraries = make(map[string]map[string]images.ImageFile)
+	}
+	f.libraries[name] = entries
+
+	merged := make(map[string]images.ImageFile)
+	for library, libraryEntries := range f.libraries {
+		for key, entry := range libraryEntries {
+			merged[images.LibraryID(library, key)] = entry
+		}
+	}
+
+	files := lo.Keys(merged)
+	if hideDuplicates {
+		files = images.UniqueKeys(merged)
+	}
+	f.Files = files
+	f.Entries = merged
+	f.reindex()
+	metrics.CatalogPhotos.WithLabelValues(metrics.LibraryLabel(name)).Set(float64(len(entries)))
+}
+
+// helper method to list the photo IDs of a single library. Handles locking
+func (f *FileHolder) LibraryFiles(name string) []string {
+	f.Mu.RLock()
+	defer f.Mu.RUnlock()
+
+	return lo.Filter(f.Files, func(id string, _ int) bool {
+		library, _ := images.SplitLibraryID(id)
+		return library == name
+	})
+}
+
+// helper method to list the names of the named libraries. Handles locking
+func (f *FileHolder) Libraries() []string {
+	f.Mu.RLock()
+	defer f.Mu.RUnlock()
+
+	names := lo.Without(lo.Keys(f.libraries), "")
+	slices.Sort(names)
+	return names
+}
+
+// helper method to list the names of the albums: the named libraries and the folders with an
+// album file, sorted. Handles locking
+func (f *FileHolder) Albums() []string {
+	f.Mu.RLock()
+	defer f.Mu.RUnlock()
+
+	names := lo.Without(lo.Keys(f.libraries), "")
+	for name := range f.albums {
+		library, folder := images.SplitAlbumName(name)
+		if _, ok := f.libraries[library]; ok && folder != "" {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// helper method to list the photo IDs of an album, including those in its subfolders that aren't
+// hidden albums. Handles locking
+func (f *FileHolder) AlbumFiles(name string) []string {
+	f.Mu.RLock()
+	defer f.Mu.RUnlock()
+
+	return lo.Filter(f.Files, func(id string, _ int) bool {
+		return images.InAlbum(id, name) && !f.hiddenWithin(id, name)
+	})
+}
+
+// helper method to list the photo IDs of the main gallery, which leaves out hidden albums. Handles locking
+func (f *FileHolder) GalleryFiles() []string {
+	f.Mu.RLock()
+	defer f.Mu.RUnlock()
+
+	// always a copy, as sorting in place under a read lock would race with other requests
+	return lo.Filter(f.Files, func(id string, _ int) bool {
+		return !f.hidden(id)
+	})
+}
+
+// hidden is true when id is in a hidden album. Must be called with Mu held
+func (f *FileHolder) hidden(id string) bool {
+	for name, album := range f.albums {
+		if album.Hidden && images.InAlbum(id, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// hiddenWithin is true when id is in a hidden album nested in album. id must be in album.
+// Must be called with Mu held
+func (f *FileHolder) hiddenWithin(id string, album string) bool {
+	for name, nested := range f.albums {
+		// both contain id, so the longer name is the deeper folder
+		if nested.Hidden && len(name) > len(album) && images.InAlbum(id, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// galleryPhoto is a photo of the main gallery with when and where it was taken
+type galleryPhoto struct {
+	id       string
+	taken    time.Time
+	location *images.Location
+}
+
+// helper method to list the photos of GalleryFiles that canView allows, newest first. Handles locking
+func (f *FileHolder) galleryPhotos(canView func(library string) bool) []galleryPhoto {
+	f.Mu.RLock()
+	defer f.Mu.RUnlock()
+
+	photos := make([]galleryPhoto, 0, len(f.Files))
+	for _, id := range f.Files {
+		library, _ := images.SplitLibraryID(id)
+		if f.hidden(id) || !canView(library) {
+			continue
+		}
+		entry := f.Entries[id]
+		metadata := entry.Metadata()
+		photos = append(photos, galleryPhoto{id: id, taken: metadata.Taken, location: metadata.Location})
+	}
+	slices.SortFunc(photos, func(a galleryPhoto, b galleryPhoto) int {
+		return cmp.Or(b.taken.Compare(a.taken), strings.Compare(a.id, b.id))
+	})
+	return photos
+}
+
+// helper method to set the sidecar metadata of an album. Handles locking
+func (f *FileHolder) SetAlbum(name string, album images.Album) {
+	f.Mu.Lock()
+	defer f.Mu.Unlock()
+
+	if f.albums == nil {
+		f.albums = make(map[string]images.Album)
+	}
+	f.albums[name] = album
+	f.reindex()
+}
+
+// helper method to replace the albums of a library with albums, keyed by their folder within
+// the library. Handles locking
+func (f *FileHolder) SetAlbums(library string, albums map[string]images.Album) {
+	f.Mu.Lock()
+	defer f.Mu.Unlock()
+
+	f.albums = lo.OmitBy(f.albums, func(name string, _ images.Album) bool {
+		albumLibrary, _ := images.SplitAlbumName(name)
+		return albumLibrary == library
+	})
+	for folder, album := range albums {
+		f.albums[images.AlbumName(library, folder)] = album
+	}
+	f.reindex()
+}
+
+// albumTitle is the title of the innermost album of id that has one. Must be called with Mu held
+func (f *FileHolder) albumTitle(id string) string {
+	title, innermost := "", ""
+	for name, album := range f.albums {
+		if album.Title != "" && images.InAlbum(id, name) && (title == "" || len(name) > len(innermost)) {
+			title, innermost = album.Title, name
+		}
+	}
+	return title
+}
+
+// reindex rebuilds the search index. Must be called with Mu held
+func (f *FileHolder) reindex() {
+	docs := lo.Map(f.Files, func(id string, _ int) search.Document {
+		entry := f.Entries[id]
+		metadata := entry.Metadata()
+		library, name := images.SplitLibraryID(id)
+		folder, _ := path.Split(name)
+		return search.Document{
+			ID:         id,
+			Name:       entry.Name(),
+			Folder:     strings.TrimSuffix(folder, "/"),
+			Album:      library,
+			AlbumTitle: f.albumTitle(id),
+			Caption:    metadata.Caption,
+			Tags:       metadata.Tags,
+			Camera:     metadata.Camera,
+			Taken:      metadata.Taken,
+		}
+	})
+	f.index = search.NewIndex(docs)
+}
+
+// helper method to search the catalog. Photos in hidden albums are only found when filtering
+// by their library's album. Handles locking
+func (f *FileHolder) Search(query search.Query, canView func(library string) bool) search.Result {
+	f.Mu.RLock()
+	index := f.index
+	hidden := lo.Keys(lo.PickBy(f.albums, func(_ string, album images.Album) bool {
+		return album.Hidden
+	}))
+	f.Mu.RUnlock()
+
+	if index == nil {
+		index = search.NewIndex(nil)
+	}
+	albumFilter := query.Filters[search.FacetAlbum]
+	return index.Search(query, func(id string) bool {
+		library, _ := images.SplitLibraryID(id)
+		inHidden := lo.SomeBy(hidden, func(album string) bool {
+			return images.InAlbum(id, album)
+		})
+		return canView(library) && (!inHidden || slices.Contains(albumFilter, library))
+	})
+}
+
+// helper method to look up the sidecar metadata of an album. Handles locking
+func (f *FileHolder) Album(name string) images.Album {
+	f.Mu.RLock()
+	defer f.Mu.RUnlock()
+
+	return f.albums[name]
+}
+
+// helper method to find the cover photo of an album: the cover named by its album file, or else
+// its first photo by name. Also returns the number of photos in the album. Handles locking
+func (f *FileHolder) Cover(name string) (id string, photos int) {
+	f.Mu.RLock()
+	defer f.Mu.RUnlock()
+
+	library, folder := images.SplitAlbumName(name)
+	keys := lo.Filter(lo.Keys(f.libraries[library]), func(key string, _ int) bool {
+		id := images.LibraryID(library, key)
+		return images.InAlbum(id, name) && !f.hiddenWithin(id, name)
+	})
+	if len(keys) == 0 {
+		return "", 0
+	}
+	cover := f.albums[name].Cover
+	if cover != "" {
+		cover = path.Join(folder, cover)
+	}
+	if !slices.Contains(keys, cover) {
+		cover = slices.Min(keys)
+	}
+	return images.LibraryID(library, cover), len(keys)
+}
+
+// helper method to look up a single entry. Handles locking
+func (f *FileHolder) Get(id string) (images.ImageFile, bool) {
+	f.Mu.RLock()
+	defer f.Mu.RUnlock()
+
+	entry, ok := f.Entries[id]
+	return entry, ok
+}
+
+// SiteSettings are the gallery options that can change while the server is running
+type SiteSettings struct {
+	Mu    sync.RWMutex
+	Title string
+	Sort  string
+	// photos per page, 0 to show every photo on one page
+	PageSize  int
+	Slideshow SlideshowSettings
+}
+
+// helper method to set the settings. Handles locking
+func (s *SiteSettings) Set(title string, sort string) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	s.Title = title
+	s.Sort = sort
+}
+
+// helper method to read the settings. Handles locking
+func (s *SiteSettings) Get() (title string, sort string) {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+
+	return s.Title, s.Sort
+}
+
+// helper method to set the page size. Handles locking
+func (s *SiteSettings) SetPageSize(size int) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	s.PageSize = size
+}
+
+// helper method to read the page size. Handles locking
+func (s *SiteSettings) GetPageSize() int {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+
+	return s.PageSize
+}
+
+// helper method to set the slideshow settings. Handles locking
+func (s *SiteSettings) SetSlideshow(slideshow SlideshowSettings) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	s.Slideshow = slideshow
+}
+
+// helper method to read the slideshow settings. Handles locking
+func (s *SiteSettings) GetSlideshow() SlideshowSettings {
+	s.Mu.RLock()
+	defer s.Mu.RUnlock()
+
+	return s.Slideshow
+}
+
+// IndexTemplate is the data index.html is rendered with. Themes depend on these fields,
+// so they are documented in web/README.md and should only be added to.
 type IndexTemplate struct {
-	Title  string
-	Photos []string
+	Title string
+	// path images are served under, previews are under <ImagePrefix>/preview
+	ImagePrefix string
+	// photos on the current page
+	Photos []Photo
+	// names of the albums the user may view
+	Albums []string
+	// album being viewed, empty for the whole gallery and share links
+	Album string
+	// logged in user, empty when anonymous
+	User     string
+	CanLogin bool
+	Page     Page
+	// gallery.title, while Title may be the name of an album
+	SiteTitle string
+	// description of the album being viewed, from its album file
+	Description string
+	// details of each album in Albums, in the same order
+	AlbumList []AlbumSummary
+	// the search box is shown, which share links leave out
+	CanSearch bool
+	// words searched for, on the search page
+	Query string
+	// filters of the search results, on the search page
+	Facets []Facet
+}
+
+// AlbumSummary describes an album for the album list
+type AlbumSummary struct {
+	Name string
+	// from the album file, or the name when not set
+	Title       string
+	Description string
+	// preview of the cover photo, empty when the album has no photos
+	CoverURL string
+	Photos   int
 }
 
 type RootHandler struct {
 	FileHolder *FileHolder
+	Settings   *SiteSettings
+	// nil when authentication is disabled
+	Auth        *Auth
+	Templates   *Templates
+	MapSettings MapSettings
 }
 
 func (rh *RootHandler) Index(w http.ResponseWriter, r *http.Request) {
 	// FIXME is it bad to aquire a read lock all the time here?
 	// Maybe better to just have eventual consistency
 	// worst that could happen is the page loads with some dead image links, solved by refresh
-	rh.FileHolder.Mu.RLock()
-	defer rh.FileHolder.Mu.RUnlock()
+	f := rh.FileHolder.GalleryFiles()
 
-	f := rh.FileHolder.Files
-	rand.NewSource(time.Now().UnixNano())
-	// shuffle the files slice
-	for i := range f {
-		j := rand.Intn(i + 1) // #nosec G404 -- secure random not required
-		f[i], f[j] = f[j], f[i]
-	}
+	title, order := rh.Settings.Get()
+	rh.render(w, r, IndexTemplate{Title: title}, order, rh.Auth.Visible(r, f))
+}
 
-	data := IndexTemplate{
-		Title:  "My Album",
-		Photos: f,
+// Album shows the photos of a single album
+func (rh *RootHandler) Album(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !slices.Contains(rh.FileHolder.Albums(), name) {
+		w.WriteHeader(http.StatusNotFound)
+		return
 	}
-
-	templateFile := "web/template/index.html"
-	t, err := template.ParseFiles(templateFile)
-	if err != nil {
-		slog.Error("Failed to parse template", "template", templateFile, "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	library, _ := images.SplitAlbumName(name)
+	if !rh.Auth.CanView(r, library) {
+		if UserFromRequest(r) == "" {
+			rh.Auth.challenge(w, r)
+			return
+		}
+		// don't reveal restricted albums to other users
+		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
-	err = t.Execute(w, &data)
-	if err != nil {
-		slog.Error("Failed to execute template", "template", templateFile, "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+	album := rh.FileHolder.Album(name)
+	_, order := rh.Settings.Get()
+	if album.Sort != "" {
+		order = album.Sort
+	}
+	rh.render(w, r, IndexTemplate{
+		Title:       lo.CoalesceOrEmpty(album.Title, name),
+		Album:       name,
+		Description: album.Description,
+	}, order, rh.FileHolder.AlbumFiles(name))
+}
+
+// render fills in the rest of data with page f of the photos and the album list
+func (rh *RootHandler) render(w http.ResponseWriter, r *http.Request, data IndexTemplate, order string, f []string) {
+	f, page := paginate(r, f, order, rh.Settings.GetPageSize())
+
+	data.SiteTitle, _ = rh.Settings.Get()
+	data.ImagePrefix = "/img"
+	data.Photos = newPhotos(rh.FileHolder, f, "/img")
+	data.AlbumList = rh.albumList(r)
+	data.Albums = lo.Map(data.AlbumList, func(album AlbumSummary, _ int) string {
+		return album.Name
+	})
+	data.User = UserFromRequest(r)
+	data.CanLogin = rh.Auth != nil
+	data.CanSearch = true
+	data.Page = page
+	rh.Templates.Render(w, http.StatusOK, "index.html", data)
+}
+
+// albumList describes the albums the user may view, leaving out hidden albums
+func (rh *RootHandler) albumList(r *http.Request) []AlbumSummary {
+	albums := make([]AlbumSummary, 0)
+	for _, name := range rh.FileHolder.Albums() {
+		album := rh.FileHolder.Album(name)
+		library, _ := images.SplitAlbumName(name)
+		if album.Hidden || !rh.Auth.CanView(r, library) {
+			continue
+		}
+		summary := AlbumSummary{
+			Name:        name,
+			Title:       lo.CoalesceOrEmpty(album.Title, name),
+			Description: album.Description,
+		}
+		cover, photos := rh.FileHolder.Cover(name)
+		if cover != "" {
+			summary.CoverURL = newPhotos(rh.FileHolder, []string{cover}, "/img")[0].PreviewURL
+		}
+		summary.Photos = photos
+		albums = append(albums, summary)
 	}
+	return albums
 }
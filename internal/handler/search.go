@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"fotodeck/internal/search"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+const (
+	defaultSearchLimit = 100
+	maxSearchLimit     = 1000
+	// facet values offered as filters on the search page, the API returns them all
+	maxFacetValues = 10
+)
+
+type SearchResponse struct {
+	Query string `json:"query"`
+	// results across all pages
+	Total  int                            `json:"total"`
+	Photos []Photo                        `json:"photos"`
+	Facets map[string][]search.FacetCount `json:"facets"`
+}
+
+// Facet is a facet of the search results shown as filter chips
+type Facet struct {
+	Name   string
+	Values []FacetValue
+}
+
+type FacetValue struct {
+	Value string
+	// results with the value
+	Count    int
+	Selected bool
+	// the search with the value toggled
+	URL string
+}
+
+// Search finds photos by the words in the q query param. Results are filtered by the facet params,
+// e.g. ?q=beach&year=2023&tag=sea, and paged by the limit and offset params.
+func (ah *ApiHandler) Search(w http.ResponseWriter, r *http.Request) {
+	limit, err := intParam(r, "limit", defaultSearchLimit)
+	if err != nil || limit < 1 || limit > maxSearchLimit {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "limit must be an integer between 1 and " + strconv.Itoa(maxSearchLimit)})
+		return
+	}
+	offset, err := intParam(r, "offset", 0)
+	if err != nil || offset < 0 {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "offset must be a positive integer"})
+		return
+	}
+
+	query := searchQuery(r)
+	result := ah.FileHolder.Search(query, func(library string) bool {
+		return ah.Auth.CanView(r, library)
+	})
+	ids := result.IDs[min(offset, len(result.IDs)):min(offset+limit, len(result.IDs))]
+	writeJson(w, http.StatusOK, SearchResponse{
+		Query:  query.Text,
+		Total:  len(result.IDs),
+		Photos: newPhotos(ah.FileHolder, ids, "/img"),
+		Facets: result.Facets,
+	})
+}
+
+// Search shows the photos matching the q query param and facet params, with the facets as filters
+func (rh *RootHandler) Search(w http.ResponseWriter, r *http.Request) {
+	query := searchQuery(r)
+	result := rh.FileHolder.Search(query, func(library string) bool {
+		return rh.Auth.CanView(r, library)
+	})
+
+	facets := make([]Facet, 0, len(search.Facets))
+	for _, name := range search.Facets {
+		facet := Facet{Name: name}
+		for i, count := range result.Facets[name] {
+			selected := slices.Contains(query.Filters[name], count.Value)
+			if i >= maxFacetValues && !selected {
+				continue
+			}
+			facet.Values = append(facet.Values, FacetValue{
+				Value:    count.Value,
+				Count:    count.Count,
+				Selected: selected,
+				URL:      toggleFilter(r.URL.Query(), name, count.Value),
+			})
+		}
+		if len(facet.Values) > 0 {
+			facets = append(facets, facet)
+		}
+	}
+
+	rh.render(w, r, IndexTemplate{
+		Title:  "Search",
+		Query:  query.Text,
+		Facets: facets,
+	}, orderUnchanged, result.IDs)
+}
+
+// searchQuery reads the q and facet query params
+func searchQuery(r *http.Request) search.Query {
+	params := r.URL.Query()
+	query := search.Query{
+		Text:    params.Get("q"),
+		Filters: make(map[string][]string),
+	}
+	for _, facet := range search.Facets {
+		if values := params[facet]; len(values) > 0 {
+			query.Filters[facet] = values
+		}
+	}
+	return query
+}
+
+// toggleFilter returns the search page URL with value of facet added to or removed from params
+func toggleFilter(params url.Values, facet string, value string) string {
+	params.Del("page")
+	values := params[facet]
+	if slices.Contains(values, value) {
+		params[facet] = slices.DeleteFunc(slices.Clone(values), func(v string) bool { return v == value })
+	} else {
+		params.Add(facet, value)
+	}
+	return "/search?" + params.Encode()
+}
+
+func intParam(r *http.Request, name string, fallback int) (int, error) {
+	param := r.URL.Query().Get(name)
+	if param == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(param)
+}
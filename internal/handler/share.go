@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fotodeck/internal/images"
+	"fotodeck/internal/share"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// ShareHandler mints share links and serves the restricted view behind them
+type ShareHandler struct {
+	FileHolder *FileHolder
+	Settings   *SiteSettings
+	// nil when authentication is disabled
+	Auth      *Auth
+	Templates *Templates
+	// HMAC key share tokens are signed with
+	Secret        []byte
+	DefaultExpiry time.Duration
+}
+
+type ShareRequest struct {
+	// exactly one of Album and Photo must be set
+	Album string `json:"album"`
+	Photo string `json:"photo"`
+	// seconds until the link expires, the configured default when 0
+	ExpiresIn int `json:"expiresIn"`
+}
+
+type ShareResponse struct {
+	Token   string    `json:"token"`
+	Url     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+// Create mints a share link for an album or photo the user can view
+func (sh *ShareHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "use POST"})
+		return
+	}
+	if sh.Auth != nil && UserFromRequest(r) == "" {
+		writeJson(w, http.StatusUnauthorized, map[string]string{"error": "log in to share photos"})
+		return
+	}
+
+	var req ShareRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req)
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "body must be JSON with an album or photo"})
+		return
+	}
+	if (req.Album == "") == (req.Photo == "") {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "exactly one of album and photo must be set"})
+		return
+	}
+	if req.ExpiresIn < 0 {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "expiresIn must not be negative"})
+		return
+	}
+
+	scope, id := share.ScopeAlbum, req.Album
+	library, _ := images.SplitAlbumName(req.Album)
+	exists := slices.Contains(sh.FileHolder.Albums(), req.Album)
+	if req.Photo != "" {
+		scope, id = share.ScopePhoto, req.Photo
+		library, _ = images.SplitLibraryID(req.Photo)
+		_, exists = sh.FileHolder.Get(req.Photo)
+	}
+	if !exists || !sh.Auth.CanView(r, library) {
+		message := scope + " not found"
+		if scope == share.ScopeAlbum {
+			// the main gallery of [home] has no album name to share it by
+			message += ", only [[libraries]] and folders with an album.toml are albums"
+		}
+		writeJson(w, http.StatusNotFound, map[string]string{"error": message})
+		return
+	}
+
+	lifetime := sh.DefaultExpiry
+	if req.ExpiresIn > 0 {
+		lifetime = time.Duration(req.ExpiresIn) * time.Second
+	}
+	token, err := share.New(scope, id, lifetime)
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	signed := share.Sign(sh.Secret, token)
+	slog.Info("created share link", "user", UserFromRequest(r), "scope", scope, "id", id, "expires", token.ExpiresAt())
+
+	writeJson(w, http.StatusCreated, ShareResponse{
+		Token:   signed,
+		Url:     "/share/" + signed,
+		Expires: token.ExpiresAt(),
+	})
+}
+
+// View shows only the photos covered by the share token
+func (sh *ShareHandler) View(w http.ResponseWriter, r *http.Request) {
+	token, ok := sh.verify(w, r)
+	if !ok {
+		return
+	}
+
+	var photos []string
+	siteTitle, order := sh.Settings.Get()
+	data := IndexTemplate{SiteTitle: siteTitle}
+	switch token.Scope {
+	case share.ScopeAlbum:
+		photos = sh.FileHolder.AlbumFiles(token.ID)
+		album := sh.FileHolder.Album(token.ID)
+		data.Title = lo.CoalesceOrEmpty(album.Title, token.ID)
+		data.Description = album.Description
+		if album.Sort != "" {
+			order = album.Sort
+		}
+	case share.ScopePhoto:
+		if _, ok := sh.FileHolder.Get(token.ID); ok {
+			photos = []string{token.ID}
+		}
+		_, data.Title = images.SplitLibraryID(token.ID)
+	}
+
+	photos, data.Page = paginate(r, photos, order, sh.Settings.GetPageSize())
+	data.ImagePrefix = "/share/" + r.PathValue("token") + "/img"
+	data.Photos = newPhotos(sh.FileHolder, photos, data.ImagePrefix)
+	sh.Templates.Render(w, http.StatusOK, "index.html", data)
+}
+
+func (sh *ShareHandler) Previews(w http.ResponseWriter, r *http.Request) {
+	entry, ok := sh.get(w, r)
+	if ok {
+		serveImage(w, r, entry.GetPreview())
+	}
+}
+
+func (sh *ShareHandler) Images(w http.ResponseWriter, r *http.Request) {
+	entry, ok := sh.get(w, r)
+	if ok {
+		serveImage(w, r, entry.GetFullSize())
+	}
+}
+
+// get looks up the {id} photo if the share token covers it, writing an error response otherwise
+func (sh *ShareHandler) get(w http.ResponseWriter, r *http.Request) (images.ImageFile, bool) {
+	token, ok := sh.verify(w, r)
+	if !ok {
+		return images.ImageFile{}, false
+	}
+	id := r.PathValue("id")
+	entry, ok := sh.FileHolder.Get(id)
+	if !ok || !token.Allows(id) {
+		w.WriteHeader(http.StatusNotFound)
+		return images.ImageFile{}, false
+	}
+	return entry, true
+}
+
+// verify checks the {token} path value, writing an error response when it isn't valid
+func (sh *ShareHandler) verify(w http.ResponseWriter, r *http.Request) (share.Token, bool) {
+	token, err := share.Verify(sh.Secret, r.PathValue("token"), time.Now())
+	if errors.Is(err, share.ErrExpiredToken) {
+		http.Error(w, "This share link has expired", http.StatusGone)
+		return token, false
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return token, false
+	}
+	return token, true
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fotodeck/internal/application"
+	"fotodeck/internal/images"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// SlideshowSettings configure the slideshow. Each can be overridden by the query param of the same name.
+type SlideshowSettings struct {
+	// seconds each photo is shown
+	Interval int `json:"interval"`
+	// one of application.SlideshowTransitions
+	Transition string `json:"transition"`
+	// show the photos in random order instead of by name
+	Shuffle bool `json:"shuffle"`
+	// only show this album, empty for the whole gallery
+	Album string `json:"album"`
+}
+
+// SlideshowTemplate is the data slideshow.html is rendered with. Themes depend on these fields,
+// so they are documented in web/README.md and should only be added to.
+type SlideshowTemplate struct {
+	Title     string
+	SiteTitle string
+	Settings  SlideshowSettings
+	// API listing the photos and settings, polled for changes
+	PhotosURL string
+}
+
+type SlideshowResponse struct {
+	Settings SlideshowSettings `json:"settings"`
+	Photos   []Photo           `json:"photos"`
+}
+
+// Slideshow shows the photos full screen one after the other, for wall displays. The page
+// follows the photos and settings of /api/slideshow, so new photos and config changes show
+// without reloading it.
+func (rh *RootHandler) Slideshow(w http.ResponseWriter, r *http.Request) {
+	settings, err := slideshowSettings(r, rh.Settings)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch _, status := slideshowFiles(r, rh.FileHolder, rh.Auth, settings.Album); status {
+	case http.StatusUnauthorized:
+		rh.Auth.challenge(w, r)
+		return
+	case http.StatusNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	siteTitle, _ := rh.Settings.Get()
+	title := "Slideshow"
+	if settings.Album != "" {
+		title = lo.CoalesceOrEmpty(rh.FileHolder.Album(settings.Album).Title, settings.Album)
+	}
+	rh.Templates.Render(w, http.StatusOK, "slideshow.html", SlideshowTemplate{
+		Title:     title,
+		SiteTitle: siteTitle,
+		Settings:  settings,
+		PhotosURL: "/api/slideshow?" + r.URL.RawQuery,
+	})
+}
+
+// Slideshow lists the photos of the slideshow with its settings. Responses carry an ETag,
+// so polling for changes is cheap.
+func (ah *ApiHandler) Slideshow(w http.ResponseWriter, r *http.Request) {
+	settings, err := slideshowSettings(r, ah.Settings)
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	ids, status := slideshowFiles(r, ah.FileHolder, ah.Auth, settings.Album)
+	switch status {
+	case http.StatusUnauthorized:
+		ah.Auth.challenge(w, r)
+		return
+	case http.StatusNotFound:
+		writeJson(w, http.StatusNotFound, map[string]string{"error": "album not found"})
+		return
+	}
+
+	body, err := json.Marshal(SlideshowResponse{Settings: settings, Photos: newPhotos(ah.FileHolder, ids, "/img")})
+	if err != nil {
+		slog.Error("Failed to encode json response", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	hash := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(hash[:8]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+// slideshowSettings are the configured settings with the query params of r applied
+func slideshowSettings(r *http.Request, siteSettings *SiteSettings) (SlideshowSettings, error) {
+	settings := siteSettings.GetSlideshow()
+	params := r.URL.Query()
+	if params.Has("interval") {
+		interval, err := strconv.Atoi(params.Get("interval"))
+		if err != nil || interval < 1 {
+			return settings, errors.New("interval must be a number of seconds of at least 1")
+		}
+		settings.Interval = interval
+	}
+	if params.Has("transition") {
+		settings.Transition = params.Get("transition")
+		if !slices.Contains(application.SlideshowTransitions, settings.Transition) {
+			return settings, errors.New("transition must be one of " + strings.Join(application.SlideshowTransitions, ", "))
+		}
+	}
+	if params.Has("shuffle") {
+		shuffle, err := strconv.ParseBool(params.Get("shuffle"))
+		if err != nil {
+			return settings, errors.New("shuffle must be true or false")
+		}
+		settings.Shuffle = shuffle
+	}
+	if params.Has("album") {
+		settings.Album = params.Get("album")
+	}
+	return settings, nil
+}
+
+// slideshowFiles lists the photos of album, or of the gallery when it is empty, by name. Hidden
+// albums are only shown when asked for. The status is http.StatusOK, http.StatusUnauthorized
+// when logging in may give access to the album, or http.StatusNotFound.
+func slideshowFiles(r *http.Request, fileHolder *FileHolder, auth *Auth, album string) ([]string, int) {
+	if album == "" {
+		ids := auth.Visible(r, fileHolder.GalleryFiles())
+		sortPhotos(ids, "name", 0)
+		return ids, http.StatusOK
+	}
+
+	if !slices.Contains(fileHolder.Albums(), album) {
+		return nil, http.StatusNotFound
+	}
+	library, _ := images.SplitAlbumName(album)
+	if !auth.CanView(r, library) {
+		if UserFromRequest(r) == "" {
+			return nil, http.StatusUnauthorized
+		}
+		// don't reveal restricted albums to other users
+		return nil, http.StatusNotFound
+	}
+	ids := fileHolder.AlbumFiles(album)
+	sortPhotos(ids, "name", 0)
+	return ids, http.StatusOK
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"bytes"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// functions available to templates in addition to the html/template builtins
+var templateFuncs = template.FuncMap{
+	"join":       strings.Join,
+	"pathEscape": url.PathEscape,
+}
+
+// Templates renders the HTML pages from the template directory of an assets file system.
+// Templates are parsed once up front, or in dev mode before every render so edits show on refresh.
+type Templates struct {
+	fs     fs.FS
+	dev    bool
+	parsed *template.Template
+}
+
+// NewTemplates parses the templates in fsys, failing if any of them are invalid
+func NewTemplates(fsys fs.FS, dev bool) (*Templates, error) {
+	t := &Templates{fs: fsys, dev: dev}
+	parsed, err := t.parse()
+	if err != nil {
+		return nil, err
+	}
+	t.parsed = parsed
+	return t, nil
+}
+
+func (t *Templates) parse() (*template.Template, error) {
+	return template.New("").Funcs(templateFuncs).ParseFS(t.fs, "template/*.html")
+}
+
+// Render executes the named template with data. Output is buffered so a failing template
+// results in a clean 500 rather than half a page.
+func (t *Templates) Render(w http.ResponseWriter, status int, name string, data any) {
+	parsed, err := t.current()
+	if err != nil {
+		slog.Error("Failed to parse template", "template", name, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	err = parsed.ExecuteTemplate(&buf, name, data)
+	if err != nil {
+		slog.Error("Failed to execute template", "template", name, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = buf.WriteTo(w)
+}
+
+// current returns the templates to render with, re-parsing them in dev mode
+func (t *Templates) current() (*template.Template, error) {
+	if t.dev {
+		return t.parse()
+	}
+	return t.parsed, nil
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"errors"
+	"io/fs"
+	"slices"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// ThemeFS overlays theme on base. A file in theme replaces the file at the same path in base,
+// and anything the theme doesn't provide falls back to base, so a theme only needs the files it changes.
+func ThemeFS(theme fs.FS, base fs.FS) fs.FS {
+	return themeFS{theme: theme, base: base}
+}
+
+type themeFS struct {
+	theme fs.FS
+	base  fs.FS
+}
+
+func (t themeFS) Open(name string) (fs.File, error) {
+	f, err := t.theme.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return t.base.Open(name)
+}
+
+// ReadDir lists the files of both, so templates only in the theme are found when parsing
+func (t themeFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	themeEntries, themeErr := fs.ReadDir(t.theme, name)
+	if themeErr != nil && !errors.Is(themeErr, fs.ErrNotExist) {
+		return nil, themeErr
+	}
+	baseEntries, baseErr := fs.ReadDir(t.base, name)
+	if baseErr != nil && !errors.Is(baseErr, fs.ErrNotExist) {
+		return nil, baseErr
+	}
+	if themeErr != nil && baseErr != nil {
+		return nil, baseErr
+	}
+
+	entries := make(map[string]fs.DirEntry)
+	for _, entry := range baseEntries {
+		entries[entry.Name()] = entry
+	}
+	for _, entry := range themeEntries {
+		entries[entry.Name()] = entry
+	}
+	merged := lo.Values(entries)
+	slices.SortFunc(merged, func(a fs.DirEntry, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return merged, nil
+}
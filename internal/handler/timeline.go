@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// groupings of /api/timeline buckets
+const (
+	GroupYear  = "year"
+	GroupMonth = "month"
+	GroupDay   = "day"
+)
+
+var timelineGroups = []string{GroupYear, GroupMonth, GroupDay}
+
+// TimelineBucket counts the photos taken in a year, month or day
+type TimelineBucket struct {
+	// e.g. 2021, 2021-04 or 2021-04-01
+	Key string `json:"key"`
+	// e.g. 2021, April 2021 or 1 April 2021
+	Label string `json:"label"`
+	Year  int    `json:"year"`
+	Month int    `json:"month,omitempty"`
+	Day   int    `json:"day,omitempty"`
+	Count int    `json:"count"`
+	// timeline page showing the bucket
+	URL string `json:"url"`
+}
+
+// TimelineDay is the photos taken on one day
+type TimelineDay struct {
+	// e.g. 2021-04-01, usable as an anchor
+	Key    string
+	Date   time.Time
+	Photos []Photo
+}
+
+// TimelineTemplate is the data timeline.html is rendered with. Themes depend on these fields,
+// so they are documented in web/README.md and should only be added to.
+type TimelineTemplate struct {
+	Title     string
+	SiteTitle string
+	// path images are served under
+	ImagePrefix string
+	// every year with photos, newest first
+	Years []TimelineBucket
+	// months of the selected year with photos, newest first
+	Months []TimelineBucket
+	// selected year, and month when only one month is shown
+	Year  int
+	Month int
+	// photos of the selection grouped by day, newest first
+	Days     []TimelineDay
+	User     string
+	CanLogin bool
+}
+
+// Timeline counts photos by the year, month or day they were taken, given by the group query param.
+// The year query param limits the buckets to one year.
+func (ah *ApiHandler) Timeline(w http.ResponseWriter, r *http.Request) {
+	group := r.URL.Query().Get("group")
+	if group == "" {
+		group = GroupMonth
+	}
+	if !slices.Contains(timelineGroups, group) {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "group must be one of year, month or day"})
+		return
+	}
+	year, err := intParam(r, "year", 0)
+	if err != nil || year < 0 {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "year must be a positive integer"})
+		return
+	}
+
+	photos := ah.FileHolder.galleryPhotos(func(library string) bool {
+		return ah.Auth.CanView(r, library)
+	})
+	if year != 0 {
+		photos = slices.DeleteFunc(photos, func(p galleryPhoto) bool { return p.taken.Year() != year })
+	}
+	writeJson(w, http.StatusOK, timelineBuckets(photos, group))
+}
+
+// Timeline shows the photos of the year query param, or only of its month when the month param is
+// set, grouped by day. Without a year the most recent year is shown. The years and months with
+// photos are listed to jump between.
+func (rh *RootHandler) Timeline(w http.ResponseWriter, r *http.Request) {
+	photos := rh.FileHolder.galleryPhotos(func(library string) bool {
+		return rh.Auth.CanView(r, library)
+	})
+
+	siteTitle, _ := rh.Settings.Get()
+	data := TimelineTemplate{
+		Title:       "Timeline",
+		SiteTitle:   siteTitle,
+		ImagePrefix: "/img",
+		Years:       timelineBuckets(photos, GroupYear),
+		User:        UserFromRequest(r),
+		CanLogin:    rh.Auth != nil,
+	}
+	year, err := intParam(r, "year", 0)
+	if (err != nil || year == 0) && len(photos) > 0 {
+		year = photos[0].taken.Year()
+	}
+	month, _ := intParam(r, "month", 0)
+	data.Year = year
+
+	photos = slices.DeleteFunc(photos, func(p galleryPhoto) bool { return p.taken.Year() != year })
+	data.Months = timelineBuckets(photos, GroupMonth)
+	if month >= 1 && month <= 12 {
+		data.Month = month
+		photos = slices.DeleteFunc(photos, func(p galleryPhoto) bool { return int(p.taken.Month()) != month })
+	}
+
+	for start := 0; start < len(photos); {
+		key := photos[start].taken.Format(time.DateOnly)
+		end := start + 1
+		for end < len(photos) && photos[end].taken.Format(time.DateOnly) == key {
+			end++
+		}
+		ids := make([]string, 0, end-start)
+		for _, photo := range photos[start:end] {
+			ids = append(ids, photo.id)
+		}
+		data.Days = append(data.Days, TimelineDay{
+			Key:    key,
+			Date:   photos[start].taken,
+			Photos: newPhotos(rh.FileHolder, ids, "/img"),
+		})
+		start = end
+	}
+	rh.Templates.Render(w, http.StatusOK, "timeline.html", data)
+}
+
+// timelineBuckets counts photos, which must be sorted newest first, by group
+func timelineBuckets(photos []galleryPhoto, group string) []TimelineBucket {
+	buckets := make([]TimelineBucket, 0)
+	for _, photo := range photos {
+		bucket := TimelineBucket{Year: photo.taken.Year()}
+		bucket.Key = strconv.Itoa(bucket.Year)
+		bucket.Label = bucket.Key
+		bucket.URL = fmt.Sprintf("/timeline?year=%d", bucket.Year)
+		if group != GroupYear {
+			bucket.Month = int(photo.taken.Month())
+			bucket.Key = photo.taken.Format("2006-01")
+			bucket.Label = photo.taken.Format("January 2006")
+			bucket.URL = fmt.Sprintf("/timeline?year=%d&month=%d", bucket.Year, bucket.Month)
+		}
+		if group == GroupDay {
+			bucket.Day = photo.taken.Day()
+			bucket.Key = photo.taken.Format(time.DateOnly)
+			bucket.Label = photo.taken.Format("2 January 2006")
+			bucket.URL += "#" + bucket.Key
+		}
+
+		if len(buckets) > 0 && buckets[len(buckets)-1].Key == bucket.Key {
+			buckets[len(buckets)-1].Count++
+			continue
+		}
+		bucket.Count = 1
+		buckets = append(buckets, bucket)
+	}
+	return buckets
+}
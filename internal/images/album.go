@@ -0,0 +1,71 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// AlbumFile is the optional sidecar describing a folder as an album. The root of a named
+// library is always an album, other folders only when they have one.
+const AlbumFile = "album.toml"
+
+// Album is the metadata of a folder from its sidecar file. Every field is optional
+type Album struct {
+	// shown instead of the library name
+	Title       string
+	Description string
+	// path within the folder of the photo shown for the album in the album list. The first photo
+	// by name when empty
+	Cover string
+	// order of the photos on the album page, overriding gallery.sort
+	Sort string
+	// leave the album out of the album list, the main gallery and the album of its parent folder.
+	// It can still be opened by its URL
+	Hidden bool
+}
+
+// LoadAlbum reads the album sidecar in dir. ok is false when there is none
+func (l *Loader) LoadAlbum(dir string) (album Album, ok bool, err error) {
+	path := filepath.Join(dir, AlbumFile)
+	meta, err := toml.DecodeFile(path, &album)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Album{}, false, nil
+	}
+	if err != nil {
+		return Album{}, false, fmt.Errorf("failed to read album file '%s': %w", path, err)
+	}
+
+	for _, key := range meta.Undecoded() {
+		slog.Warn("unknown album key will be ignored", "path", path, "key", key.String())
+	}
+	return album, true, nil
+}
+
+// AlbumName names the album of a folder, given by its slash separated path within library.
+// The root of a named library is named after it, other folders like the IDs of their photos.
+// Folders of the unnamed library always start with ":", so they can't be mistaken for a library.
+func AlbumName(library string, folder string) string {
+	if folder == "" {
+		return library
+	}
+	return library + ":" + folder
+}
+
+// SplitAlbumName is the inverse of AlbumName
+func SplitAlbumName(name string) (library string, folder string) {
+	library, folder, _ = strings.Cut(name, ":")
+	return library, folder
+}
+
+// InAlbum is true when the photo id is in the folder of album or one of its subfolders
+func InAlbum(id string, album string) bool {
+	library, folder := SplitAlbumName(album)
+	idLibrary, name := SplitLibraryID(id)
+	return idLibrary == library && (folder == "" || strings.HasPrefix(name, folder+"/"))
+}
@@ -0,0 +1,99 @@
+package images
+
+import (
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// formats EXIF is read from. The decoder scans other formats in full looking for an EXIF segment
+var exifExtensions = []string{".jpg", ".jpeg", ".tif", ".tiff"}
+
+// exifInfo is the part of the EXIF of an original kept as metadata
+type exifInfo struct {
+	camera   string
+	taken    time.Time
+	location *Location
+}
+
+// readExif returns the EXIF of the original at path. Unchanged files are served from a cache.
+// Photos without a capture date are dated by their modification time.
+func (l *Loader) readExif(path string) exifInfo {
+	info, err := os.Stat(path)
+	if err != nil {
+		slog.Warn("failed to read EXIF", "path", path, "error", err)
+		return exifInfo{}
+	}
+	if l.exif == nil {
+		l.exif = newFileCache[exifInfo]()
+	}
+	if cached, ok := l.exif.get(path, info); ok {
+		return cached
+	}
+
+	result := exifInfo{taken: info.ModTime()}
+	if slices.Contains(exifExtensions, strings.ToLower(filepath.Ext(path))) {
+		decodeExif(path, &result)
+	}
+	l.exif.set(path, info, result)
+	return result
+}
+
+func decodeExif(path string, result *exifInfo) {
+	f, err := os.Open(path)
+	if err != nil {
+		slog.Warn("failed to read EXIF", "path", path, "error", err)
+		return
+	}
+	defer f.Close()
+
+	x, err := exif.Decode(f)
+	if err != nil {
+		slog.Debug("no EXIF", "path", path, "error", err, "class", "Loader")
+		return
+	}
+	if taken, err := x.DateTime(); err == nil {
+		result.taken = taken
+	}
+	result.camera = cameraName(exifString(x, exif.Make), exifString(x, exif.Model))
+	if latitude, longitude, err := x.LatLong(); err == nil {
+		result.location = newLocation(latitude, longitude)
+	}
+}
+
+// newLocation returns nil for coordinates that can't be real. Cameras without a fix often write 0, 0
+func newLocation(latitude float64, longitude float64) *Location {
+	if math.IsNaN(latitude) || math.IsNaN(longitude) || math.Abs(latitude) > 90 || math.Abs(longitude) > 180 {
+		return nil
+	}
+	if latitude == 0 && longitude == 0 {
+		return nil
+	}
+	return &Location{Latitude: latitude, Longitude: longitude}
+}
+
+func exifString(x *exif.Exif, field exif.FieldName) string {
+	tag, err := x.Get(field)
+	if err != nil {
+		return ""
+	}
+	value, err := tag.StringVal()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(value, "\x00"))
+}
+
+// cameraName joins the make and model, which often already starts with the make, e.g. "Canon" and "Canon EOS 70D"
+func cameraName(make string, model string) string {
+	if model == "" || make == "" || strings.HasPrefix(strings.ToLower(model), strings.ToLower(make)) {
+		return strings.TrimSpace(model)
+	}
+	return make + " " + model
+}
@@ -0,0 +1,96 @@
+package images
+
+import (
+	"image"
+	"image/draw"
+	"image/gif"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+func IsGif(path string) bool {
+	return strings.EqualFold(fileExtension(path), "gif")
+}
+
+func OpenGif(inputPath string) (*gif.GIF, error) {
+	f, err := os.Open(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return gif.DecodeAll(f)
+}
+
+// SaveGif encodes every frame of src. The file is replaced atomically.
+func SaveGif(src *gif.GIF, outputPath string) error {
+	return writeFileAtomic(outputPath, func(w io.Writer) error {
+		return gif.EncodeAll(w, src)
+	})
+}
+
+// ResizeGif resizes every frame of an animated GIF, preserving frame delays and looping.
+// Frames are composited onto a full canvas before resizing so that partial frames
+// and disposal methods render the same as the original.
+func ResizeGif(src *gif.GIF, maxDimensions Dimensions) *gif.GIF {
+	canvasBounds := image.Rect(0, 0, src.Config.Width, src.Config.Height)
+	if canvasBounds.Empty() && len(src.Image) > 0 {
+		canvasBounds = src.Image[0].Bounds()
+	}
+	original := Dimensions{Width: canvasBounds.Dx(), Height: canvasBounds.Dy()}
+	resized := calculateDimensions(original, maxDimensions)
+
+	canvas := image.NewRGBA(canvasBounds)
+	frames := make([]*image.Paletted, 0, len(src.Image))
+	disposals := make([]byte, 0, len(src.Image))
+
+	for i, frame := range src.Image {
+		var previous *image.RGBA
+		disposal := frameDisposal(src, i)
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(canvasBounds)
+			draw.Draw(previous, canvasBounds, canvas, canvasBounds.Min, draw.Src)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		scaled := imaging.Resize(canvas, resized.Width, resized.Height, imaging.CatmullRom)
+		paletted := image.NewPaletted(scaled.Bounds(), frame.Palette)
+		draw.FloydSteinberg.Draw(paletted, scaled.Bounds(), scaled, image.Point{})
+		frames = append(frames, paletted)
+		// every output frame covers the whole canvas so it can simply be drawn over the last
+		disposals = append(disposals, gif.DisposalNone)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return &gif.GIF{
+		Image:     frames,
+		Delay:     src.Delay,
+		LoopCount: src.LoopCount,
+		Disposal:  disposals,
+		Config: image.Config{
+			Width:  resized.Width,
+			Height: resized.Height,
+		},
+	}
+}
+
+func IsAnimated(src *gif.GIF) bool {
+	return len(src.Image) > 1
+}
+
+func frameDisposal(src *gif.GIF, i int) byte {
+	if i < len(src.Disposal) {
+		return src.Disposal[i]
+	}
+	return gif.DisposalNone
+}
@@ -0,0 +1,110 @@
+package images
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type DuplicateGroup struct {
+	Hash  string   `json:"hash"`
+	Files []string `json:"files"`
+}
+
+// fileCache avoids recomputing values for unchanged originals on every reload
+type fileCache[T any] struct {
+	mu      sync.Mutex
+	entries map[string]fileCacheEntry[T]
+}
+
+type fileCacheEntry[T any] struct {
+	size    int64
+	modTime time.Time
+	value   T
+}
+
+func newFileCache[T any]() *fileCache[T] {
+	return &fileCache[T]{entries: make(map[string]fileCacheEntry[T])}
+}
+
+func (c *fileCache[T]) get(path string, info os.FileInfo) (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[path]
+	if !ok || entry.size != info.Size() || !entry.modTime.Equal(info.ModTime()) {
+		var zero T
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (c *fileCache[T]) set(path string, info os.FileInfo, value T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[path] = fileCacheEntry[T]{size: info.Size(), modTime: info.ModTime(), value: value}
+}
+
+// HashFile returns the hex encoded SHA-256 of the file contents
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// FindDuplicates groups entries with identical content. Only groups with more than one file are returned.
+func FindDuplicates(entries map[string]ImageFile) []DuplicateGroup {
+	byHash := make(map[string][]string)
+	for key, entry := range entries {
+		if entry.Hash() == "" {
+			continue
+		}
+		byHash[entry.Hash()] = append(byHash[entry.Hash()], key)
+	}
+
+	groups := make([]DuplicateGroup, 0)
+	for hash, keys := range byHash {
+		if len(keys) < 2 {
+			continue
+		}
+		slices.Sort(keys)
+		groups = append(groups, DuplicateGroup{Hash: hash, Files: keys})
+	}
+	slices.SortFunc(groups, func(a, b DuplicateGroup) int {
+		return strings.Compare(a.Files[0], b.Files[0])
+	})
+	return groups
+}
+
+// UniqueKeys returns the keys of entries with duplicates removed, keeping the first key of each group
+func UniqueKeys(entries map[string]ImageFile) []string {
+	hidden := make(map[string]bool)
+	for _, group := range FindDuplicates(entries) {
+		for _, key := range group.Files[1:] {
+			hidden[key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		if !hidden[key] {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
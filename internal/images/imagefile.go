@@ -1,9 +1,11 @@
 package images
 
 import (
+	"errors"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type ImageFile struct {
@@ -11,6 +13,11 @@ type ImageFile struct {
 	optimisedPath string
 	previewPath   string
 	name          string
+	hash          string
+	// perceptual hash of the preview, only valid when hasPerceptualHash is set
+	perceptualHash    uint64
+	hasPerceptualHash bool
+	metadata          Metadata
 }
 
 func NewImageFile(name string, path string) ImageFile {
@@ -40,6 +47,34 @@ func (i *ImageFile) Name() string {
 	return i.name
 }
 
+// Hash is the hex encoded SHA-256 of the original, empty until the image has been hashed
+func (i *ImageFile) Hash() string {
+	return i.hash
+}
+
+// PerceptualHash is the dHash of the image, ok is false for images that could not be hashed
+func (i *ImageFile) PerceptualHash() (hash uint64, ok bool) {
+	return i.perceptualHash, i.hasPerceptualHash
+}
+
+// Metadata is the caption, tags and rating from the sidecars of the photo
+func (i *ImageFile) Metadata() Metadata {
+	return i.metadata
+}
+
+// WithMetadata returns a copy of the image annotated with metadata
+func (i ImageFile) WithMetadata(metadata Metadata) ImageFile {
+	i.metadata = metadata
+	return i
+}
+
+// withDerivativesOf points this image at the resized files of an identical original
+func (i ImageFile) withDerivativesOf(original ImageFile) ImageFile {
+	i.optimisedPath = original.optimisedPath
+	i.previewPath = original.previewPath
+	return i
+}
+
 func (i *ImageFile) IsOptimised() bool {
 	return i.optimisedPath != ""
 }
@@ -48,16 +83,36 @@ func (i *ImageFile) Cleanup() error {
 	if i.optimisedPath != "" {
 		slog.Info("removing optimised file", "path", filepath.Clean(i.optimisedPath))
 		err := os.Remove(i.optimisedPath)
-		if err != nil {
+		// derivatives shared between duplicates may already have been removed
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
 			return err
 		}
 	}
 	if i.previewPath != "" {
 		slog.Info("removing preview file", "path", filepath.Clean(i.optimisedPath))
 		err := os.Remove(i.previewPath)
-		if err != nil {
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
 			return err
 		}
 	}
 	return nil
 }
+
+// LibraryID namespaces a file name with the library it belongs to. Files of the
+// unnamed library keep their name as the ID, unless it contains ":", which is then
+// prefixed with ":" so the name is not mistaken for a library.
+func LibraryID(library string, name string) string {
+	if library == "" && !strings.Contains(name, ":") {
+		return name
+	}
+	return library + ":" + name
+}
+
+// SplitLibraryID is the inverse of LibraryID
+func SplitLibraryID(id string) (library string, name string) {
+	library, name, ok := strings.Cut(id, ":")
+	if !ok {
+		return "", id
+	}
+	return library, name
+}
@@ -1,11 +1,14 @@
 package images
 
 import (
+	"fotodeck/internal/metrics"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 type Loader struct {
@@ -13,6 +16,13 @@ type Loader struct {
 	PreviewExtension       string
 	MaxOptimisedDimensions Dimensions
 	MaxPreviewDimensions   Dimensions
+	// follow symlinks to files and directories whose targets are within the home path or AllowedRoots
+	FollowSymlinks bool
+	AllowedRoots   []string
+
+	hashes           *fileCache[string]
+	perceptualHashes *fileCache[uint64]
+	exif             *fileCache[exifInfo]
 }
 
 func (l *Loader) Reload(homePath string) (map[string]ImageFile, error) {
@@ -30,30 +40,17 @@ func (l *Loader) Reload(homePath string) (map[string]ImageFile, error) {
 func (l *Loader) LoadOriginals(homePath string) (map[string]ImageFile, error) {
 	slog.Info("Loading original Images from homePath", "path", homePath, "class", "Loader")
 	fileMap := make(map[string]ImageFile)
-	err := filepath.WalkDir(homePath, func(path string, f os.DirEntry, err error) error {
-		if err != nil {
-			return err
+	err := l.walkOriginals(homePath, func(path string, name string) {
+		if strings.Contains(name, l.OptimisedExtension) || strings.Contains(name, l.PreviewExtension) {
+			slog.Debug("skipping already optimised file", "path", name, "class", "Loader", "optExt", l.OptimisedExtension, "prvExt", l.PreviewExtension)
+			return
 		}
-		if !f.Type().IsRegular() {
-			return nil
-		}
-		if strings.Contains(f.Name(), l.OptimisedExtension) || strings.Contains(f.Name(), l.PreviewExtension) {
-			slog.Debug("skipping already optimised file", "path", f.Name(), "class", "Loader", "optExt", l.OptimisedExtension, "prvExt", l.PreviewExtension)
-			return nil
-		}
-		if !isFiletypeAllowed(f.Name()) {
-			slog.Debug("skipping non-image file", "path", f.Name(), "class", "Loader")
-			return nil
+		if !isFiletypeAllowed(name) {
+			slog.Debug("skipping non-image file", "path", name, "class", "Loader")
+			return
 		}
 
-		existingPath, ok := fileMap[f.Name()]
-		if ok {
-			slog.Warn("duplicate filename entry found (existingPath). path will be used instead", "path", path, "existingPath", existingPath)
-		}
-
-		fileMap[f.Name()] = NewImageFile(f.Name(), path)
-
-		return nil
+		fileMap[relativeKey(homePath, path)] = NewImageFile(name, path).WithMetadata(l.loadMetadata(path))
 	})
 	if err != nil {
 		return nil, err
@@ -62,11 +59,21 @@ func (l *Loader) LoadOriginals(homePath string) (map[string]ImageFile, error) {
 	return fileMap, nil
 }
 
+// relativeKey is the catalogue key of the file at path, its slash separated path relative to root.
+// Files directly in root are keyed by name, and same-named files in different folders are kept apart.
+func relativeKey(root string, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return filepath.Base(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
 func (l *Loader) IsResizedImage(path string) bool {
 	return strings.Contains(path, "."+l.OptimisedExtension+".") || strings.Contains(path, "."+l.PreviewExtension+".")
 }
 
-func (l *Loader) worker(optExt string, prvExt string, jobs <-chan struct {
+func worker(fn func(ImageFile) ImageFile, jobs <-chan struct {
 	string
 	ImageFile
 }, results chan<- struct {
@@ -74,22 +81,19 @@ func (l *Loader) worker(optExt string, prvExt string, jobs <-chan struct {
 	ImageFile
 }) {
 	for item := range jobs {
+		metrics.WorkerQueueDepth.Dec()
 		key := item.string
 		image := item.ImageFile
 
-		optimised, err := l.OptimiseImage(image, optExt, prvExt)
-		if err != nil {
-			slog.Error("optimiseImageError", "error", err)
-		}
-
 		results <- struct {
 			string
 			ImageFile
-		}{key, optimised}
+		}{key, fn(image)}
 	}
 }
 
-func (l *Loader) OptimiseImages(images *map[string]ImageFile) error {
+// processImages applies fn to every image using one worker per CPU, replacing the entries in place
+func processImages(images *map[string]ImageFile, fn func(ImageFile) ImageFile) {
 	numCpus := runtime.NumCPU()
 	imageCount := len(*images)
 
@@ -105,9 +109,10 @@ func (l *Loader) OptimiseImages(images *map[string]ImageFile) error {
 	}, imageCount)
 
 	for i := 0; i < numCpus; i++ {
-		go l.worker(l.OptimisedExtension, l.PreviewExtension, jobs, results)
+		go worker(fn, jobs, results)
 	}
 
+	metrics.WorkerQueueDepth.Add(float64(imageCount))
 	for k, v := range *images {
 		jobs <- struct {
 			string
@@ -124,22 +129,134 @@ func (l *Loader) OptimiseImages(images *map[string]ImageFile) error {
 		(*images)[key] = val
 	}
 	close(results)
+}
+
+// HashImages computes the content hash of every original. Unchanged files are served from a cache.
+func (l *Loader) HashImages(images *map[string]ImageFile) error {
+	if l.hashes == nil {
+		l.hashes = newFileCache[string]()
+	}
+
+	processImages(images, func(image ImageFile) ImageFile {
+		info, err := os.Stat(image.originalPath)
+		if err != nil {
+			slog.Error("hashImageError", "path", image.originalPath, "error", err)
+			return image
+		}
+		if hash, ok := l.hashes.get(image.originalPath, info); ok {
+			image.hash = hash
+			return image
+		}
+
+		hash, err := HashFile(image.originalPath)
+		if err != nil {
+			slog.Error("hashImageError", "path", image.originalPath, "error", err)
+			return image
+		}
+		l.hashes.set(image.originalPath, info, hash)
+		image.hash = hash
+		return image
+	})
+
+	return nil
+}
+
+func (l *Loader) OptimiseImages(images *map[string]ImageFile) error {
+	err := l.HashImages(images)
+	if err != nil {
+		return err
+	}
+
+	// identical originals share one set of derivatives, so only the first of each group is optimised
+	toOptimise, sharedWith := splitDuplicates(*images)
+
+	processImages(&toOptimise, func(image ImageFile) ImageFile {
+		optimised, err := l.OptimiseImage(image, l.OptimisedExtension, l.PreviewExtension)
+		if err != nil {
+			slog.Error("optimiseImageError", "error", err)
+		}
+		return optimised
+	})
+
+	for k, v := range toOptimise {
+		(*images)[k] = v
+	}
+	for k, original := range sharedWith {
+		duplicate := (*images)[k]
+		(*images)[k] = duplicate.withDerivativesOf(toOptimise[original])
+	}
+
+	return l.PerceptualHashImages(images)
+}
+
+// splitDuplicates separates the images that own their derivatives from the duplicates
+// that share them. sharedWith maps each duplicate key to the key owning its derivatives.
+func splitDuplicates(images map[string]ImageFile) (owners map[string]ImageFile, sharedWith map[string]string) {
+	sharedWith = make(map[string]string)
+	for _, group := range FindDuplicates(images) {
+		for _, key := range group.Files[1:] {
+			sharedWith[key] = group.Files[0]
+		}
+	}
+	owners = make(map[string]ImageFile, len(images))
+	for k, v := range images {
+		if _, ok := sharedWith[k]; !ok {
+			owners[k] = v
+		}
+	}
+	return owners, sharedWith
+}
+
+// PerceptualHashImages computes the dHash of every image. The preview is used where
+// available as it is much cheaper to decode than the original.
+func (l *Loader) PerceptualHashImages(images *map[string]ImageFile) error {
+	if l.perceptualHashes == nil {
+		l.perceptualHashes = newFileCache[uint64]()
+	}
+
+	processImages(images, func(image ImageFile) ImageFile {
+		path := image.GetPreview()
+		if IsSvg(path) {
+			return image
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			slog.Error("perceptualHashError", "path", path, "error", err)
+			return image
+		}
+		if hash, ok := l.perceptualHashes.get(path, info); ok {
+			image.perceptualHash, image.hasPerceptualHash = hash, true
+			return image
+		}
+
+		src, err := Open(path)
+		if err != nil {
+			slog.Error("perceptualHashError", "path", path, "error", err)
+			return image
+		}
+		hash := DifferenceHash(src)
+		l.perceptualHashes.set(path, info, hash)
+		image.perceptualHash, image.hasPerceptualHash = hash, true
+		return image
+	})
 
 	return nil
 }
 
 func (l *Loader) OptimiseImage(image ImageFile, optimisedExt string, previewExt string) (ImageFile, error) {
-	optimisedPath := l.resizeImage(image.GetFullSize(), optimisedExt, l.MaxOptimisedDimensions)
-	previewPath := l.resizeImage(image.GetFullSize(), previewExt, l.MaxPreviewDimensions)
+	// vector images are served as-is, there is nothing to resize
+	if IsSvg(image.GetFullSize()) {
+		return image, nil
+	}
 
-	return ImageFile{
-		name:          image.Name(),
-		originalPath:  image.GetFullSize(),
-		optimisedPath: optimisedPath,
-		previewPath:   previewPath,
-	}, nil
+	image.optimisedPath = l.resizeImage(image.originalPath, optimisedExt, l.MaxOptimisedDimensions)
+	image.previewPath = l.resizeImage(image.originalPath, previewExt, l.MaxPreviewDimensions)
+
+	return image, nil
 }
 
+// resizeImage writes the derivative of inputPath with extension and returns its path, or an empty
+// path when it could not be written, so the image is served from the original instead
 func (l *Loader) resizeImage(inputPath string, extension string, maxDimensions Dimensions) string {
 	outputPath := l.getOptimisedFilePath(inputPath, extension)
 	if _, err := os.Stat(outputPath); err == nil {
@@ -147,11 +264,28 @@ func (l *Loader) resizeImage(inputPath string, extension string, maxDimensions D
 		return outputPath
 	}
 
+	return l.writeDerivative(inputPath, outputPath, extension, maxDimensions)
+}
+
+// writeDerivative resizes inputPath into outputPath, replacing any existing file atomically. It
+// returns outputPath, or an empty path when the derivative could not be written.
+func (l *Loader) writeDerivative(inputPath string, outputPath string, extension string, maxDimensions Dimensions) string {
 	slog.Info("resizing image", "extension", extension, "path", filepath.Clean(outputPath))
+	class := metrics.ClassPreview
+	if extension == l.OptimisedExtension {
+		class = metrics.ClassOptimised
+	}
+	timer := prometheus.NewTimer(metrics.ResizeDuration.WithLabelValues(class))
+	defer timer.ObserveDuration()
+
+	if IsGif(inputPath) {
+		return l.resizeGif(inputPath, outputPath, maxDimensions)
+	}
+
 	image, err := Open(inputPath)
 	if err != nil {
 		slog.Error("error opening image to resize", "error", err)
-		return inputPath
+		return ""
 	}
 
 	image = Resize(image, maxDimensions)
@@ -159,7 +293,29 @@ func (l *Loader) resizeImage(inputPath string, extension string, maxDimensions D
 	err = Save(image, outputPath)
 	if err != nil {
 		slog.Error("error saving image to resize", "error", err)
-		return inputPath
+		return ""
+	}
+
+	return outputPath
+}
+
+// resizeGif resizes a GIF frame by frame so animations are kept in the derivative
+func (l *Loader) resizeGif(inputPath string, outputPath string, maxDimensions Dimensions) string {
+	src, err := OpenGif(inputPath)
+	if err != nil {
+		slog.Error("error opening gif to resize", "error", err)
+		return ""
+	}
+
+	if !IsAnimated(src) {
+		image := Resize(src.Image[0], maxDimensions)
+		err = Save(image, outputPath)
+	} else {
+		err = SaveGif(ResizeGif(src, maxDimensions), outputPath)
+	}
+	if err != nil {
+		slog.Error("error saving gif to resize", "error", err)
+		return ""
 	}
 
 	return outputPath
@@ -183,9 +339,12 @@ func (l *Loader) getOptimisedFilePath(inputPath string, extension string) string
 
 func isFiletypeAllowed(fileName string) bool {
 	whitelist := []string{"png", "jpeg", "jpg", "svg", "gif"}
-	_type := fileName[strings.LastIndex(fileName, ".")+1:]
 
-	return stringInSlice(strings.ToLower(_type), whitelist)
+	return stringInSlice(strings.ToLower(fileExtension(fileName)), whitelist)
+}
+
+func fileExtension(fileName string) string {
+	return fileName[strings.LastIndex(fileName, ".")+1:]
 }
 
 func stringInSlice(a string, list []string) bool {
@@ -0,0 +1,124 @@
+package images
+
+import (
+	"image"
+	"math/bits"
+	"slices"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+type SimilarImage struct {
+	ID       string `json:"id"`
+	Distance int    `json:"distance"`
+}
+
+// DifferenceHash computes a 64 bit dHash: the image is shrunk to 9x8 greyscale
+// and each bit records whether a pixel is brighter than its right neighbour.
+// Visually similar images produce hashes with a small Hamming distance.
+func DifferenceHash(src image.Image) uint64 {
+	small := imaging.Grayscale(imaging.Resize(src, 9, 8, imaging.Box))
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			left := small.Pix[small.PixOffset(x, y)]
+			right := small.Pix[small.PixOffset(x+1, y)]
+			hash <<= 1
+			if left > right {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+func HammingDistance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// FindSimilar returns the images within maxDistance of the image with the given id, closest first
+func FindSimilar(entries map[string]ImageFile, id string, maxDistance int) []SimilarImage {
+	similar := make([]SimilarImage, 0)
+	target, ok := entries[id]
+	if !ok {
+		return similar
+	}
+	targetHash, ok := target.PerceptualHash()
+	if !ok {
+		return similar
+	}
+
+	for key, entry := range entries {
+		hash, ok := entry.PerceptualHash()
+		if key == id || !ok {
+			continue
+		}
+		distance := HammingDistance(targetHash, hash)
+		if distance <= maxDistance {
+			similar = append(similar, SimilarImage{ID: key, Distance: distance})
+		}
+	}
+	slices.SortFunc(similar, func(a, b SimilarImage) int {
+		if a.Distance != b.Distance {
+			return a.Distance - b.Distance
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return similar
+}
+
+// ClusterSimilar groups images whose perceptual hashes are within maxDistance of
+// any other member of the group. Only clusters with more than one image are returned.
+func ClusterSimilar(entries map[string]ImageFile, maxDistance int) [][]string {
+	keys := make([]string, 0, len(entries))
+	for key, entry := range entries {
+		if _, ok := entry.PerceptualHash(); ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	// union-find over the key indices
+	parent := make([]int, len(keys))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range keys {
+		a := entries[keys[i]]
+		hashA, _ := a.PerceptualHash()
+		for j := i + 1; j < len(keys); j++ {
+			b := entries[keys[j]]
+			hashB, _ := b.PerceptualHash()
+			if HammingDistance(hashA, hashB) <= maxDistance {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	byRoot := make(map[int][]string)
+	for i, key := range keys {
+		root := find(i)
+		byRoot[root] = append(byRoot[root], key)
+	}
+
+	clusters := make([][]string, 0)
+	for _, cluster := range byRoot {
+		if len(cluster) > 1 {
+			clusters = append(clusters, cluster)
+		}
+	}
+	slices.SortFunc(clusters, func(a, b []string) int {
+		return strings.Compare(a[0], b[0])
+	})
+	return clusters
+}
@@ -2,6 +2,9 @@ package images
 
 import (
 	"image"
+	"io"
+	"os"
+	"path/filepath"
 
 	"github.com/disintegration/imaging"
 )
@@ -35,8 +38,44 @@ func Resize(src image.Image, maxDimensions Dimensions) image.Image {
 	return resizedImg
 }
 
+// Save encodes src in the format of the extension of outputPath. The file is replaced atomically.
 func Save(src image.Image, outputPath string) error {
-	return imaging.Save(src, outputPath)
+	format, err := imaging.FormatFromFilename(outputPath)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(outputPath, func(w io.Writer) error {
+		return imaging.Encode(w, src, format)
+	})
+}
+
+// writeFileAtomic writes to a temporary file in the directory of outputPath and renames it over
+// outputPath once complete, so a crash or a concurrent read never sees a partly written file.
+// The temporary name keeps the extensions of outputPath, so the loader and watcher skip it too.
+func writeFileAtomic(outputPath string, write func(w io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(outputPath), filepath.Base(outputPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// fails harmlessly once renamed
+	defer os.Remove(f.Name())
+
+	err = write(f)
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), outputPath)
 }
 
 // calculateDimensions determines the new dimensions based on the maximum constraints
@@ -0,0 +1,180 @@
+package images
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// namespaces of the XMP properties read from sidecars
+const (
+	xmpDublinCore = "http://purl.org/dc/elements/1.1/"
+	xmpBasic      = "http://ns.adobe.com/xap/1.0/"
+)
+
+const maxRating = 5
+
+// Metadata describes a photo. The caption, tags and rating annotate it without touching the original:
+// they are read from sidecar files next to the photo, an XMP sidecar written by photo editors and a
+// photo.jpg.toml sidecar whose values win. The rest comes from the EXIF of the original.
+type Metadata struct {
+	Caption string
+	Tags    []string
+	// stars from 1 to 5, 0 when unrated
+	Rating int
+	// make and model
+	Camera string
+	// when the photo was taken, or the modification time of files without a capture date
+	Taken time.Time
+	// where the photo was taken, nil when it isn't geotagged
+	Location *Location
+}
+
+// Location is a point in WGS 84 degrees, as recorded by GPS
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// sidecar is the photo.jpg.toml format
+type sidecar struct {
+	Caption string
+	Tags    []string
+	Rating  int
+}
+
+// loadMetadata merges the sidecars of the photo at path with its EXIF. Missing sidecars are skipped,
+// and broken ones are logged and skipped so a typo doesn't hide the photo.
+func (l *Loader) loadMetadata(path string) Metadata {
+	var metadata Metadata
+	// photo.jpg.xmp as written by darktable, photo.xmp as written by Lightroom
+	for _, xmpPath := range []string{path + ".xmp", strings.TrimSuffix(path, filepath.Ext(path)) + ".xmp"} {
+		xmpMetadata, err := readXMP(xmpPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			slog.Warn("skipping unreadable XMP sidecar", "path", xmpPath, "error", err)
+			continue
+		}
+		metadata = mergeMetadata(metadata, xmpMetadata)
+		break
+	}
+
+	tomlPath := path + ".toml"
+	var tomlSidecar sidecar
+	meta, err := toml.DecodeFile(tomlPath, &tomlSidecar)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		slog.Warn("skipping unreadable sidecar", "path", tomlPath, "error", err)
+	default:
+		for _, key := range meta.Undecoded() {
+			slog.Warn("unknown sidecar key will be ignored", "path", tomlPath, "key", key.String())
+		}
+		if tomlSidecar.Rating < 0 || tomlSidecar.Rating > maxRating {
+			slog.Warn("ignoring sidecar rating outside 0 to 5", "path", tomlPath, "rating", tomlSidecar.Rating)
+			tomlSidecar.Rating = 0
+		}
+		metadata = mergeMetadata(metadata, Metadata{Caption: tomlSidecar.Caption, Tags: tomlSidecar.Tags, Rating: tomlSidecar.Rating})
+	}
+
+	exif := l.readExif(path)
+	metadata.Camera = exif.camera
+	metadata.Taken = exif.taken
+	metadata.Location = exif.location
+	return metadata
+}
+
+// mergeMetadata overrides the caption and rating of base with those set in override, and adds its tags
+func mergeMetadata(base Metadata, override Metadata) Metadata {
+	if override.Caption != "" {
+		base.Caption = override.Caption
+	}
+	if override.Rating != 0 {
+		base.Rating = override.Rating
+	}
+	for _, tag := range override.Tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(base.Tags, tag) {
+			base.Tags = append(base.Tags, tag)
+		}
+	}
+	return base
+}
+
+func readXMP(path string) (Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Metadata{}, err
+	}
+	defer f.Close()
+	return parseXMP(f)
+}
+
+// parseXMP reads the description, keywords and rating of an XMP packet. Only the first
+// description is used, which by convention is the x-default language.
+func parseXMP(r io.Reader) (Metadata, error) {
+	var metadata Metadata
+	decoder := xml.NewDecoder(r)
+	// dc property whose rdf:li items are being read
+	property := ""
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return metadata, nil
+		}
+		if err != nil {
+			return Metadata{}, fmt.Errorf("invalid XMP: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			for _, attr := range t.Attr {
+				if attr.Name.Space == xmpBasic && attr.Name.Local == "Rating" {
+					metadata.Rating = parseRating(attr.Value)
+				}
+			}
+			if t.Name.Space == xmpDublinCore && (t.Name.Local == "description" || t.Name.Local == "subject") {
+				property = t.Name.Local
+			}
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			value := strings.TrimSpace(text.String())
+			switch {
+			case t.Name.Local == "li" && property == "description" && metadata.Caption == "":
+				metadata.Caption = value
+			case t.Name.Local == "li" && property == "subject":
+				metadata = mergeMetadata(metadata, Metadata{Tags: []string{value}})
+			case t.Name.Space == xmpDublinCore && t.Name.Local == property:
+				property = ""
+			case t.Name.Space == xmpBasic && t.Name.Local == "Rating":
+				metadata.Rating = parseRating(value)
+			}
+			text.Reset()
+		}
+	}
+}
+
+// parseRating reads an XMP rating, where -1 marks a rejected photo. Anything outside 1 to 5 is unrated
+func parseRating(value string) int {
+	rating, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || rating < 0 || rating > maxRating {
+		return 0
+	}
+	return rating
+}
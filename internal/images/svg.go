@@ -0,0 +1,150 @@
+package images
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const SvgContentType = "image/svg+xml"
+
+// elements that can execute script or embed foreign content
+var svgBlockedElements = []string{"script", "foreignobject", "iframe", "embed", "object"}
+
+func IsSvg(path string) bool {
+	return strings.EqualFold(fileExtension(path), "svg")
+}
+
+// SanitiseSvg copies the SVG document from r to w, stripping script elements,
+// event handler attributes and javascript: URLs
+func SanitiseSvg(r io.Reader, w io.Writer) error {
+	decoder := xml.NewDecoder(r)
+	out := bufio.NewWriter(w)
+
+	// RawToken does not check element nesting, so track open elements here
+	var open []xml.Name
+	// depth of the blocked element currently being skipped, 0 when not skipping
+	skipDepth := 0
+	for {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse svg: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			open = append(open, t.Name)
+			if skipDepth == 0 && isBlockedSvgElement(t.Name) {
+				skipDepth = len(open)
+			}
+			if skipDepth != 0 {
+				continue
+			}
+			t.Attr = sanitiseSvgAttrs(t.Attr)
+			token = t
+		case xml.EndElement:
+			if len(open) == 0 || open[len(open)-1] != t.Name {
+				return fmt.Errorf("failed to parse svg: unexpected end element </%s>", qualifiedName(t.Name))
+			}
+			open = open[:len(open)-1]
+			if skipDepth != 0 {
+				if len(open) < skipDepth {
+					skipDepth = 0
+				}
+				continue
+			}
+		case xml.ProcInst:
+			if skipDepth != 0 || t.Target != "xml" {
+				continue
+			}
+		case xml.CharData:
+			if skipDepth != 0 {
+				continue
+			}
+		default:
+			// drop comments, DOCTYPE and entity declarations
+			continue
+		}
+
+		err = writeSvgToken(out, token)
+		if err != nil {
+			return fmt.Errorf("failed to write svg: %w", err)
+		}
+	}
+	if len(open) != 0 {
+		return fmt.Errorf("failed to parse svg: unclosed element <%s>", qualifiedName(open[len(open)-1]))
+	}
+
+	return out.Flush()
+}
+
+// SanitiseSvgFile writes a sanitised copy of the SVG at path to w
+func SanitiseSvgFile(path string, w io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return SanitiseSvg(f, w)
+}
+
+// writeSvgToken serialises a raw token, keeping namespace prefixes as they appeared in the source
+func writeSvgToken(w *bufio.Writer, token xml.Token) error {
+	switch t := token.(type) {
+	case xml.StartElement:
+		w.WriteString("<" + qualifiedName(t.Name))
+		for _, attr := range t.Attr {
+			w.WriteString(" " + qualifiedName(attr.Name) + `="`)
+			err := xml.EscapeText(w, []byte(attr.Value))
+			if err != nil {
+				return err
+			}
+			w.WriteString(`"`)
+		}
+		w.WriteString(">")
+	case xml.EndElement:
+		w.WriteString("</" + qualifiedName(t.Name) + ">")
+	case xml.CharData:
+		return xml.EscapeText(w, t)
+	case xml.ProcInst:
+		w.WriteString("<?" + t.Target + " " + string(t.Inst) + "?>")
+	}
+	return nil
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+func isBlockedSvgElement(name xml.Name) bool {
+	return stringInSlice(strings.ToLower(name.Local), svgBlockedElements)
+}
+
+func sanitiseSvgAttrs(attrs []xml.Attr) []xml.Attr {
+	sanitised := make([]xml.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		name := strings.ToLower(attr.Name.Local)
+		if strings.HasPrefix(name, "on") {
+			continue
+		}
+		if name == "href" {
+			value := strings.ToLower(strings.TrimSpace(attr.Value))
+			if strings.HasPrefix(value, "javascript:") || strings.HasPrefix(value, "data:text/html") {
+				continue
+			}
+		}
+		sanitised = append(sanitised, attr)
+	}
+	return sanitised
+}
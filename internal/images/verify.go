@@ -0,0 +1,201 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+type DerivativeStatus int
+
+const (
+	DerivativeOk DerivativeStatus = iota
+	DerivativeMissing
+	DerivativeCorrupt
+	DerivativeStale
+)
+
+func (s DerivativeStatus) String() string {
+	switch s {
+	case DerivativeOk:
+		return "ok"
+	case DerivativeMissing:
+		return "missing"
+	case DerivativeCorrupt:
+		return "corrupt"
+	case DerivativeStale:
+		return "stale"
+	}
+	return "unknown"
+}
+
+type DerivativeReport struct {
+	Original string
+	Path     string
+	Status   DerivativeStatus
+	Err      error
+	// set by RepairImages once the derivative has been regenerated
+	Repaired bool
+
+	derivative derivative
+}
+
+// derivative describes one resized copy of an original
+type derivative struct {
+	path          string
+	extension     string
+	maxDimensions Dimensions
+}
+
+func (l *Loader) derivatives(image ImageFile) []derivative {
+	if IsSvg(image.originalPath) {
+		return nil
+	}
+	return []derivative{
+		{
+			path:          l.getOptimisedFilePath(image.originalPath, l.OptimisedExtension),
+			extension:     l.OptimisedExtension,
+			maxDimensions: l.MaxOptimisedDimensions,
+		},
+		{
+			path:          l.getOptimisedFilePath(image.originalPath, l.PreviewExtension),
+			extension:     l.PreviewExtension,
+			maxDimensions: l.MaxPreviewDimensions,
+		},
+	}
+}
+
+// DerivativePaths returns the paths the resized copies of image are written to
+func (l *Loader) DerivativePaths(image ImageFile) []string {
+	paths := make([]string, 0, 2)
+	for _, d := range l.derivatives(image) {
+		paths = append(paths, d.path)
+	}
+	return paths
+}
+
+// VerifyImage checks that every derivative of image exists, is newer than the original,
+// decodes, and has the dimensions the current resize settings would produce
+func (l *Loader) VerifyImage(image ImageFile) []DerivativeReport {
+	reports := make([]DerivativeReport, 0, 2)
+	original, err := os.Stat(image.originalPath)
+	if err != nil {
+		return reports
+	}
+
+	for _, d := range l.derivatives(image) {
+		report := DerivativeReport{Original: image.originalPath, Path: d.path, Status: DerivativeOk, derivative: d}
+		info, err := os.Stat(d.path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			report.Status = DerivativeMissing
+		case err != nil:
+			report.Status, report.Err = DerivativeCorrupt, err
+		case info.Size() == 0:
+			report.Status, report.Err = DerivativeCorrupt, errors.New("empty file")
+		case info.ModTime().Before(original.ModTime()):
+			report.Status, report.Err = DerivativeStale, errors.New("original modified after resize")
+		default:
+			report.Status, report.Err = inspectDerivative(image.originalPath, d)
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// VerifyImages returns the derivatives that are missing, corrupt or stale, sorted by path.
+// Duplicates sharing derivatives with another original are only checked once.
+func (l *Loader) VerifyImages(images map[string]ImageFile) ([]DerivativeReport, error) {
+	// hashing replaces entries, so work on a copy as the caller's map may be served concurrently
+	images = maps.Clone(images)
+	err := l.HashImages(&images)
+	if err != nil {
+		return nil, err
+	}
+	owners, _ := splitDuplicates(images)
+
+	problems := make([]DerivativeReport, 0)
+	for _, entry := range owners {
+		for _, report := range l.VerifyImage(entry) {
+			if report.Status != DerivativeOk {
+				problems = append(problems, report)
+			}
+		}
+	}
+	slices.SortFunc(problems, func(a, b DerivativeReport) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return problems, nil
+}
+
+// RepairImages regenerates every missing, corrupt or stale derivative. All problems found are
+// returned, with Repaired set on those that were regenerated successfully.
+func (l *Loader) RepairImages(images map[string]ImageFile) ([]DerivativeReport, error) {
+	problems, err := l.VerifyImages(images)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, p := range problems {
+		// the broken derivative is replaced atomically, so it is served until the new one is complete
+		if l.writeDerivative(p.Original, p.Path, p.derivative.extension, p.derivative.maxDimensions) != p.Path {
+			problems[i].Err = errors.New("failed to regenerate derivative")
+			continue
+		}
+		problems[i].Repaired = true
+	}
+	return problems, nil
+}
+
+// inspectDerivative fully decodes the derivative to catch truncated files, and compares
+// its dimensions with those the original would be resized to now
+func inspectDerivative(originalPath string, d derivative) (DerivativeStatus, error) {
+	actual, err := decodeDimensions(d.path)
+	if err != nil {
+		return DerivativeCorrupt, err
+	}
+	source, err := decodeConfigDimensions(originalPath)
+	if err != nil {
+		// the original can't be read, there is nothing to compare against
+		return DerivativeOk, nil
+	}
+
+	expected := calculateDimensions(source, d.maxDimensions)
+	if actual != expected {
+		return DerivativeStale, fmt.Errorf("dimensions %dx%d, expected %dx%d", actual.Width, actual.Height, expected.Width, expected.Height)
+	}
+	return DerivativeOk, nil
+}
+
+func decodeDimensions(path string) (Dimensions, error) {
+	if IsGif(path) {
+		src, err := OpenGif(path)
+		if err != nil {
+			return Dimensions{}, err
+		}
+		return Dimensions{Width: src.Config.Width, Height: src.Config.Height}, nil
+	}
+	src, err := Open(path)
+	if err != nil {
+		return Dimensions{}, err
+	}
+	return Dimensions{Width: src.Bounds().Dx(), Height: src.Bounds().Dy()}, nil
+}
+
+func decodeConfigDimensions(path string) (Dimensions, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Dimensions{}, err
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return Dimensions{}, err
+	}
+	return Dimensions{Width: config.Width, Height: config.Height}, nil
+}
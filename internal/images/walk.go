@@ -0,0 +1,140 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var errOutsideRoots = errors.New("symlink target is outside the library and allowed roots")
+
+// walkOriginals calls visit for every regular file under root, in lexical order.
+// Symlinks are skipped unless l.FollowSymlinks is set. Followed symlinks must resolve to a path within
+// root or one of l.AllowedRoots, and each directory is only walked once so symlink loops terminate.
+func (l *Loader) walkOriginals(root string, visit func(path string, name string)) error {
+	return l.walk(root, func(string) {}, visit)
+}
+
+// Folders lists root and every folder under it that LoadOriginals walks, with the same symlink policy
+func (l *Loader) Folders(root string) ([]string, error) {
+	folders := make([]string, 0)
+	err := l.walk(root, func(dir string) {
+		folders = append(folders, dir)
+	}, func(string, string) {})
+	return folders, err
+}
+
+// walk calls visitDir for root and every directory under it, and visit for every regular file,
+// as described by walkOriginals
+func (l *Loader) walk(root string, visitDir func(dir string), visit func(path string, name string)) error {
+	resolvedRoot, err := resolvePath(root)
+	if err != nil {
+		return err
+	}
+
+	allowed := []string{resolvedRoot}
+	for _, r := range l.AllowedRoots {
+		resolved, err := resolvePath(r)
+		if err != nil {
+			slog.Warn("ignoring allowed root that can't be resolved", "root", r, "error", err)
+			continue
+		}
+		allowed = append(allowed, resolved)
+	}
+
+	w := walker{loader: l, allowed: allowed, visited: make(map[string]bool), visitDir: visitDir, visit: visit}
+	return w.walkDir(root, resolvedRoot)
+}
+
+type walker struct {
+	loader *Loader
+	// resolved paths symlink targets must be within
+	allowed []string
+	// resolved paths of directories already walked
+	visited  map[string]bool
+	visitDir func(dir string)
+	visit    func(path string, name string)
+}
+
+// walkDir walks dir, whose path with all symlinks resolved is resolvedDir
+func (w *walker) walkDir(dir string, resolvedDir string) error {
+	if w.visited[resolvedDir] {
+		slog.Warn("skipping directory already walked through another path, possibly a symlink loop", "path", dir, "target", resolvedDir)
+		return nil
+	}
+	w.visited[resolvedDir] = true
+	w.visitDir(dir)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		resolved := filepath.Join(resolvedDir, entry.Name())
+		mode := entry.Type()
+
+		if mode&fs.ModeSymlink != 0 {
+			if !w.loader.FollowSymlinks {
+				slog.Debug("skipping symlink", "path", path, "class", "Loader")
+				continue
+			}
+			resolved, mode, err = w.resolveSymlink(path)
+			if err != nil {
+				slog.Warn("skipping symlink", "path", path, "error", err)
+				continue
+			}
+		}
+
+		switch {
+		case mode.IsDir():
+			err = w.walkDir(path, resolved)
+			if err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			w.visit(path, entry.Name())
+		}
+	}
+	return nil
+}
+
+// resolveSymlink returns the target of the symlink at path, if it is within the allowed roots
+func (w *walker) resolveSymlink(path string) (string, fs.FileMode, error) {
+	target, err := resolvePath(path)
+	if err != nil {
+		return "", 0, err
+	}
+	if !isWithinRoots(target, w.allowed) {
+		return "", 0, fmt.Errorf("%w: %s", errOutsideRoots, target)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return "", 0, err
+	}
+	return target, info.Mode().Type(), nil
+}
+
+// resolvePath returns the absolute path of path with all symlinks resolved
+func resolvePath(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(resolved)
+}
+
+// isWithinRoots is true when path is one of roots or inside one of them. All paths must be resolved.
+func isWithinRoots(path string, roots []string) bool {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
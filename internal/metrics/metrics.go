@@ -0,0 +1,84 @@
+// Package metrics holds the Prometheus metrics exported on /metrics
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "fotodeck"
+
+// derivative classes for ResizeDuration
+const (
+	ClassOptimised = "optimised"
+	ClassPreview   = "preview"
+)
+
+var (
+	HttpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by route pattern, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	HttpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to handle HTTP requests, by route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route"})
+
+	HttpResponseBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_response_bytes_total",
+		Help:      "Bytes written in HTTP response bodies, by route pattern.",
+	}, []string{"route"})
+
+	ResizeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "resize_duration_seconds",
+		Help:      "Time taken to generate a resized image, by derivative class.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"class"})
+
+	CatalogPhotos = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "catalog_photos",
+		Help:      "Photos in the catalog, by library.",
+	}, []string{"library"})
+
+	WatcherEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "watcher_events_total",
+		Help:      "File system events seen in library directories, by library and operation.",
+	}, []string{"library", "op"})
+
+	ReloadDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "reload_duration_seconds",
+		Help:      "Time taken to reload and optimise a library after changes, by library.",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300},
+	}, []string{"library"})
+
+	WorkerQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "worker_queue_depth",
+		Help:      "Images waiting for an image processing worker.",
+	})
+)
+
+// LibraryLabel is the library label value, naming the unnamed [home] library "home"
+func LibraryLabel(library string) string {
+	if library == "" {
+		return "home"
+	}
+	return library
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
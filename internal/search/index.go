@@ -0,0 +1,214 @@
+// Package search is an in-memory full text and faceted index of the photo catalog
+package search
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/samber/lo"
+)
+
+// facets results can be filtered and counted by
+const (
+	FacetYear   = "year"
+	FacetCamera = "camera"
+	FacetTag    = "tag"
+	FacetAlbum  = "album"
+)
+
+// Facets are counted in every result, in this order
+var Facets = []string{FacetYear, FacetCamera, FacetTag, FacetAlbum}
+
+// Document is the searchable description of a photo
+type Document struct {
+	ID   string
+	Name string
+	// slash separated path of the folder within the library, empty at its root
+	Folder string
+	Album  string
+	// title from the album file, searched as well as the album name
+	AlbumTitle string
+	Caption    string
+	Tags       []string
+	Camera     string
+	Taken      time.Time
+}
+
+// values of facet for the document
+func (d *Document) facetValues(facet string) []string {
+	switch facet {
+	case FacetYear:
+		if d.Taken.IsZero() {
+			return nil
+		}
+		return []string{strconv.Itoa(d.Taken.Year())}
+	case FacetCamera:
+		if d.Camera == "" {
+			return nil
+		}
+		return []string{d.Camera}
+	case FacetTag:
+		return d.Tags
+	case FacetAlbum:
+		if d.Album == "" {
+			return nil
+		}
+		return []string{d.Album}
+	}
+	return nil
+}
+
+// Query finds the documents matching every word of Text and every value of Filters
+type Query struct {
+	// words match the start of any word of a file name, folder, album, caption, tag, camera or year
+	Text string
+	// selected values keyed by facet
+	Filters map[string][]string
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type Result struct {
+	// matching photo IDs, newest first
+	IDs []string
+	// number of results with each value, keyed by facet
+	Facets map[string][]FacetCount
+}
+
+// Index is immutable once built, so it can be searched from any goroutine
+type Index struct {
+	docs map[string]*Document
+	// IDs of the documents containing each word
+	postings map[string][]string
+	// keys of postings, sorted for prefix lookups
+	words []string
+}
+
+// NewIndex indexes docs
+func NewIndex(docs []Document) *Index {
+	index := &Index{
+		docs:     make(map[string]*Document, len(docs)),
+		postings: make(map[string][]string),
+	}
+	for i := range docs {
+		doc := &docs[i]
+		index.docs[doc.ID] = doc
+		for _, word := range lo.Uniq(documentWords(doc)) {
+			index.postings[word] = append(index.postings[word], doc.ID)
+		}
+	}
+	index.words = lo.Keys(index.postings)
+	slices.Sort(index.words)
+	return index
+}
+
+// Search returns the documents matching query that allowed accepts
+func (ix *Index) Search(query Query, allowed func(id string) bool) Result {
+	var matches []*Document
+	for id, doc := range ix.candidates(query.Text) {
+		if allowed(id) && matchesFilters(doc, query.Filters) {
+			matches = append(matches, doc)
+		}
+	}
+	slices.SortFunc(matches, func(a *Document, b *Document) int {
+		return cmp.Or(b.Taken.Compare(a.Taken), strings.Compare(a.ID, b.ID))
+	})
+
+	result := Result{
+		IDs:    make([]string, 0, len(matches)),
+		Facets: make(map[string][]FacetCount),
+	}
+	counts := make(map[string]map[string]int)
+	for _, doc := range matches {
+		result.IDs = append(result.IDs, doc.ID)
+		for _, facet := range Facets {
+			if counts[facet] == nil {
+				counts[facet] = make(map[string]int)
+			}
+			for _, value := range doc.facetValues(facet) {
+				counts[facet][value]++
+			}
+		}
+	}
+	for _, facet := range Facets {
+		values := make([]FacetCount, 0, len(counts[facet]))
+		for value, count := range counts[facet] {
+			values = append(values, FacetCount{Value: value, Count: count})
+		}
+		slices.SortFunc(values, func(a FacetCount, b FacetCount) int {
+			return cmp.Or(b.Count-a.Count, strings.Compare(a.Value, b.Value))
+		})
+		result.Facets[facet] = values
+	}
+	return result
+}
+
+// candidates are the documents containing a word starting with each word of text
+func (ix *Index) candidates(text string) map[string]*Document {
+	words := lo.Uniq(tokenize(text))
+	if len(words) == 0 {
+		return ix.docs
+	}
+
+	var matched map[string]*Document
+	for _, word := range words {
+		found := make(map[string]*Document)
+		start, _ := slices.BinarySearch(ix.words, word)
+		for _, indexed := range ix.words[start:] {
+			if !strings.HasPrefix(indexed, word) {
+				break
+			}
+			for _, id := range ix.postings[indexed] {
+				if matched == nil || matched[id] != nil {
+					found[id] = ix.docs[id]
+				}
+			}
+		}
+		matched = found
+		if len(matched) == 0 {
+			break
+		}
+	}
+	return matched
+}
+
+// matchesFilters is true when the document has every selected value of every facet
+func matchesFilters(doc *Document, filters map[string][]string) bool {
+	for facet, selected := range filters {
+		values := doc.facetValues(facet)
+		for _, value := range selected {
+			if !slices.ContainsFunc(values, func(v string) bool { return strings.EqualFold(v, value) }) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func documentWords(doc *Document) []string {
+	words := make([]string, 0)
+	for _, text := range []string{doc.Name, doc.Folder, doc.Album, doc.AlbumTitle, doc.Caption, doc.Camera} {
+		words = append(words, tokenize(text)...)
+	}
+	for _, tag := range doc.Tags {
+		words = append(words, tokenize(tag)...)
+	}
+	for _, year := range doc.facetValues(FacetYear) {
+		words = append(words, year)
+	}
+	return words
+}
+
+// tokenize splits text into lower case words of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,108 @@
+package share
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fotodeck/internal/images"
+	"strings"
+	"time"
+)
+
+const (
+	ScopeAlbum = "album"
+	ScopePhoto = "photo"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid share token")
+	ErrExpiredToken = errors.New("share token has expired")
+)
+
+// Token grants read access to a single album or photo until it expires
+type Token struct {
+	// ScopeAlbum or ScopePhoto
+	Scope string `json:"scope"`
+	// library name for albums, photo ID for photos
+	ID      string `json:"id"`
+	Expires int64  `json:"exp"`
+}
+
+// New creates a token for the album or photo id expiring after lifetime
+func New(scope string, id string, lifetime time.Duration) (Token, error) {
+	if scope != ScopeAlbum && scope != ScopePhoto {
+		return Token{}, fmt.Errorf("scope must be %s or %s, got '%s'", ScopeAlbum, ScopePhoto, scope)
+	}
+	if id == "" {
+		return Token{}, fmt.Errorf("%s must not be empty", scope)
+	}
+	if lifetime <= 0 {
+		return Token{}, fmt.Errorf("lifetime must be positive, got %s", lifetime)
+	}
+	return Token{
+		Scope:   scope,
+		ID:      id,
+		Expires: time.Now().Add(lifetime).Unix(),
+	}, nil
+}
+
+// ExpiresAt is the time the token stops being accepted
+func (t Token) ExpiresAt() time.Time {
+	return time.Unix(t.Expires, 0)
+}
+
+// Allows is true when the photo id is covered by the token
+func (t Token) Allows(id string) bool {
+	switch t.Scope {
+	case ScopeAlbum:
+		library, _ := images.SplitLibraryID(id)
+		return library == t.ID
+	case ScopePhoto:
+		return id == t.ID
+	}
+	return false
+}
+
+// Sign encodes the token as '<payload>.<signature>', both base64url encoded,
+// with an HMAC-SHA256 signature keyed by secret
+func Sign(secret []byte, t Token) string {
+	// marshalling a struct of strings and ints can't fail
+	payload, _ := json.Marshal(t)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signature(secret, encoded))
+}
+
+// Verify checks the signature and expiry of a token created by Sign
+func Verify(secret []byte, token string, now time.Time) (Token, error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return Token{}, ErrInvalidToken
+	}
+	decodedSig, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(decodedSig, signature(secret, encoded)) {
+		return Token{}, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Token{}, ErrInvalidToken
+	}
+	var t Token
+	err = json.Unmarshal(payload, &t)
+	if err != nil || (t.Scope != ScopeAlbum && t.Scope != ScopePhoto) {
+		return Token{}, ErrInvalidToken
+	}
+	if !now.Before(t.ExpiresAt()) {
+		return Token{}, ErrExpiredToken
+	}
+	return t, nil
+}
+
+func signature(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
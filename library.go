@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fotodeck/internal/application"
+	"fotodeck/internal/handler"
+	"fotodeck/internal/images"
+	"fotodeck/internal/metrics"
+
+	"errors"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// startLibrary loads and optimises a library into fileHolder, then watches it for changes in the background.
+// Config updates sent on updates are applied to the library. Progress is reported to health.
+func startLibrary(conf application.Config, library application.Library, fileHolder *handler.FileHolder, health *handler.Health, updates <-chan application.Config) {
+	loader := application.NewLoader(library)
+	fileEntries, fileLoadErr := loader.LoadOriginals(library.Path)
+	if fileLoadErr != nil {
+		slog.Error("failed to load library", "library", library.Name, "path", library.Path, "error", fileLoadErr)
+		fileEntries = make(map[string]images.ImageFile)
+	}
+	fileLoadErr = loader.OptimiseImages(&fileEntries)
+	if fileLoadErr != nil {
+		slog.Error("failed to optimimise images: ", "library", library.Name, "error", fileLoadErr)
+	}
+	fileHolder.SetLibrary(library.Name, fileEntries, conf.Home.HideDuplicates)
+	loadAlbums(&loader, library, fileEntries, fileHolder)
+	health.SetLoaded(library.Name)
+	slog.Info("loaded library", "library", library.Name, "path", library.Path, "photos", len(fileEntries))
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Error("failed to initialise file watcher. File watch will be disabled", "library", library.Name, "error", err)
+		watcher = nil
+	} else {
+		err = watcher.Add(library.Path)
+		if err != nil {
+			slog.Error("failed to add library path to file watcher. File watch will be disabled", "library", library.Name, "error", err)
+		} else {
+			watchFolders(watcher, &loader, library)
+		}
+	}
+	health.SetWatching(library.Name, err == nil, err)
+
+	throttle := time.NewTicker(time.Duration(library.MinRefreshInterval) * time.Second)
+	go func() {
+		if watcher != nil {
+			defer watcher.Close()
+		}
+		// verified before watching rather than alongside it, as the watcher owns the loader and the resized files
+		if conf.ImageResizing.VerifyOnStartup {
+			repairLibrary(&loader, library, fileHolder, fileEntries, conf.Home.HideDuplicates)
+		}
+		fileWatchFn(watcher, &loader, fileHolder, throttle, library, conf.Home.HideDuplicates, updates)
+		health.SetWatching(library.Name, false, errors.New("file watcher stopped"))
+	}()
+}
+
+// loadAlbums reads the album files of a library into fileHolder. The root of a named library is
+// always an album, and so is each of its folders with photos that has an album file. The root of
+// the unnamed library is the main gallery, which is described by [gallery] instead.
+func loadAlbums(loader *images.Loader, library application.Library, fileEntries map[string]images.ImageFile, fileHolder *handler.FileHolder) {
+	known := fileHolder.Albums()
+	albums := make(map[string]images.Album)
+	for _, folder := range albumFolders(fileEntries) {
+		name := images.AlbumName(library.Name, folder)
+		dir := filepath.Join(library.Path, filepath.FromSlash(folder))
+		album, ok, err := loader.LoadAlbum(dir)
+		if err != nil {
+			slog.Error("failed to load album file, keeping the previous album details", "album", name, "error", err)
+			album, ok = fileHolder.Album(name), slices.Contains(known, name)
+		}
+		if name == "" {
+			if ok {
+				slog.Warn("ignoring album file of the main gallery, it is described by [gallery]", "path", library.Path)
+			}
+			continue
+		}
+		if !ok && folder != "" {
+			continue
+		}
+		if album.Sort != "" && !slices.Contains(application.SortOrders, album.Sort) {
+			slog.Warn("ignoring invalid album sort", "album", name, "sort", album.Sort, "sortOrders", application.SortOrders)
+			album.Sort = ""
+		}
+		if _, ok := fileEntries[path.Join(folder, album.Cover)]; album.Cover != "" && !ok {
+			slog.Warn("album cover not found, using the first photo instead", "album", name, "cover", album.Cover)
+		}
+		albums[folder] = album
+	}
+	fileHolder.SetAlbums(library.Name, albums)
+}
+
+// albumFolders lists the folders of fileEntries and their parents, starting with the root ""
+func albumFolders(fileEntries map[string]images.ImageFile) []string {
+	folders := []string{""}
+	for key := range fileEntries {
+		for folder := path.Dir(key); folder != "."; folder = path.Dir(folder) {
+			folders = append(folders, folder)
+		}
+	}
+	slices.Sort(folders)
+	return slices.Compact(folders)
+}
+
+// watchFolders adds the folders under a library to watcher, as fsnotify only watches the folders
+// it is given. Adding a folder again is harmless, so it is repeated on reload to pick up new ones.
+func watchFolders(watcher *fsnotify.Watcher, loader *images.Loader, library application.Library) {
+	folders, err := loader.Folders(library.Path)
+	if err != nil {
+		slog.Error("failed to list library folders, changes in new folders will be missed", "library", library.Name, "error", err)
+	}
+	for _, folder := range folders {
+		err = watcher.Add(folder)
+		if err != nil {
+			slog.Warn("failed to watch folder, changes in it will be missed", "library", library.Name, "path", folder, "error", err)
+		}
+	}
+}
+
+// refreshLibrary reloads and optimises a library into fileHolder
+func refreshLibrary(loader *images.Loader, library application.Library, fileHolder *handler.FileHolder, hideDuplicates bool) (map[string]images.ImageFile, error) {
+	start := time.Now()
+	fileEntries, err := loader.Reload(library.Path)
+	metrics.ReloadDuration.WithLabelValues(metrics.LibraryLabel(library.Name)).Observe(time.Since(start).Seconds())
+	if err != nil {
+		return nil, err
+	}
+	fileHolder.SetLibrary(library.Name, fileEntries, hideDuplicates)
+	loadAlbums(loader, library, fileEntries, fileHolder)
+	return fileEntries, nil
+}
+
+// repairLibrary regenerates the broken resized images of fileEntries. The library is refreshed
+// when any were repaired, so photos served from their original after a failed resize pick them up.
+func repairLibrary(loader *images.Loader, library application.Library, fileHolder *handler.FileHolder, fileEntries map[string]images.ImageFile, hideDuplicates bool) {
+	if repairDerivatives(loader, fileEntries) == 0 {
+		return
+	}
+	_, err := refreshLibrary(loader, library, fileHolder, hideDuplicates)
+	if err != nil {
+		slog.Error("failed to reload library after repairing resized images", "library", library.Name, "error", err)
+	}
+}
+
+// repairDerivatives regenerates resized images left corrupt or stale, e.g. by a crash mid-write.
+// It returns the number repaired.
+func repairDerivatives(loader *images.Loader, fileEntries map[string]images.ImageFile) int {
+	slog.Info("verifying resized images")
+	reports, err := loader.RepairImages(fileEntries)
+	if err != nil {
+		slog.Error("failed to verify resized images", "error", err)
+		return 0
+	}
+
+	repaired := 0
+	for _, r := range reports {
+		if r.Repaired {
+			repaired++
+			slog.Info("repaired resized image", "path", r.Path, "status", r.Status.String(), "reason", r.Err)
+		} else {
+			slog.Error("failed to repair resized image", "path", r.Path, "status", r.Status.String(), "error", r.Err)
+		}
+	}
+	slog.Info("resized image verification complete", "problems", len(reports), "repaired", repaired)
+	return repaired
+}
+
+func fileWatchFn(watcher *fsnotify.Watcher, loader *images.Loader, fileHolder *handler.FileHolder, throttle *time.Ticker, library application.Library, hideDuplicates bool, configUpdates <-chan application.Config) {
+	defer throttle.Stop()
+	var hasNewEvent bool
+
+	// nil channels block forever, so a missing watcher just never produces events
+	var watchEvents <-chan fsnotify.Event
+	var watchErrors <-chan error
+	if watcher != nil {
+		watchEvents = watcher.Events
+		watchErrors = watcher.Errors
+	}
+
+	for {
+		select {
+		case event, ok := <-watchEvents:
+			if !ok {
+				return
+			}
+			// prevent circular update loop
+			if !loader.IsResizedImage(event.Name) {
+				metrics.WatcherEvents.WithLabelValues(metrics.LibraryLabel(library.Name), event.Op.String()).Inc()
+				slog.Info("watcherEvent", "library", library.Name, "event", event)
+				hasNewEvent = true
+			}
+		case err, ok := <-watchErrors:
+			if !ok {
+				return
+			}
+			slog.Error("watcherError: ", "library", library.Name, "err", err)
+		case <-throttle.C:
+			if hasNewEvent {
+				// before reloading, so nothing added to a new folder in between is missed
+				watchFolders(watcher, loader, library)
+				_, fileLoadErr := refreshLibrary(loader, library, fileHolder, hideDuplicates)
+				if fileLoadErr != nil {
+					slog.Error("failed to reload library", "library", library.Name, "error", fileLoadErr)
+					continue
+				}
+				slog.Info("watcherEvent: library refresh completed", "library", library.Name)
+				hasNewEvent = false
+			}
+		case conf := <-configUpdates:
+			updated, ok := conf.Library(library.Name)
+			if !ok {
+				// removing a library requires a restart
+				continue
+			}
+			// only the live options, see liveConfigKeys
+			library.MinRefreshInterval = updated.MinRefreshInterval
+			library.ImageResizing.ResizedWidth = updated.ImageResizing.ResizedWidth
+			library.ImageResizing.ResizedHeight = updated.ImageResizing.ResizedHeight
+			library.ImageResizing.PreviewWidth = updated.ImageResizing.PreviewWidth
+			library.ImageResizing.PreviewHeight = updated.ImageResizing.PreviewHeight
+			application.ResizeLoader(loader, library)
+			throttle.Reset(time.Duration(library.MinRefreshInterval) * time.Second)
+			hideDuplicates = conf.Home.HideDuplicates
+
+			fileEntries, fileLoadErr := refreshLibrary(loader, library, fileHolder, hideDuplicates)
+			if fileLoadErr != nil {
+				slog.Error("failed to reload library", "library", library.Name, "error", fileLoadErr)
+				continue
+			}
+			// regenerate derivatives left with the old dimensions
+			repairLibrary(loader, library, fileHolder, fileEntries, hideDuplicates)
+			slog.Info("configReload: library refresh completed", "library", library.Name)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fotodeck/internal/application"
+
+	"io"
+	"log/slog"
+	"os"
+)
+
+// setupLogging replaces the default logger according to [log]. The level can be changed later through logLevel.
+// Returns the logger for access logs, nil when they are disabled, and a func closing the access log file.
+func setupLogging(conf application.Config, logLevel *slog.LevelVar) (*slog.Logger, func(), error) {
+	logLevel.Set(conf.LogLevel())
+	slog.SetDefault(slog.New(newLogHandler(os.Stderr, conf.Log.Format, logLevel)))
+
+	closeFn := func() {}
+	if !conf.Log.Access {
+		return nil, closeFn, nil
+	}
+	if conf.Log.AccessFile == "" {
+		return slog.Default(), closeFn, nil
+	}
+
+	// #nosec G302 G304 -- the access log path is set by the admin
+	file, err := os.OpenFile(conf.Log.AccessFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, closeFn, err
+	}
+	closeFn = func() {
+		_ = file.Close()
+	}
+	// the file is dedicated to access logs, so keep every request
+	return slog.New(newLogHandler(file, conf.Log.Format, slog.LevelDebug)), closeFn, nil
+}
+
+func newLogHandler(w io.Writer, format string, level slog.Leveler) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == "json" {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fotodeck/internal/application"
+	"fotodeck/internal/handler"
+
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// config options applied without a restart. Changes to any other option are logged as requiring one.
+var liveConfigKeys = []string{
+	"log.level",
+	"gallery.title",
+	"gallery.sort",
+	"gallery.pageSize",
+	"slideshow.interval",
+	"slideshow.transition",
+	"slideshow.shuffle",
+	"slideshow.album",
+	"home.minRefreshInterval",
+	"home.hideDuplicates",
+	"imageResizing.previewWidth",
+	"imageResizing.previewHeight",
+	"imageResizing.resizedWidth",
+	"imageResizing.resizedHeight",
+	// the file extensions are left out, as derivatives written with the old ones would be loaded as originals
+}
+
+// editors often write a file in several steps, wait for them to settle before reloading
+const configReloadDelay = 500 * time.Millisecond
+
+// configReloader re-reads the config on SIGHUP or when the config file changes,
+// applying the options in liveConfigKeys to the running server
+type configReloader struct {
+	path      string
+	overrides application.Overrides
+	current   application.Config
+	settings  *handler.SiteSettings
+	logLevel  *slog.LevelVar
+	// library options are applied by the file watch goroutine of each library, which owns its loader.
+	// Each channel holds at most one pending update, see sendLatest
+	libraryUpdates []chan application.Config
+}
+
+func (c *configReloader) run(hup <-chan os.Signal) {
+	var fileEvents <-chan fsnotify.Event
+	if c.path != "" {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			slog.Error("failed to initialise config watcher. Reload with SIGHUP instead", "error", err)
+		} else {
+			defer watcher.Close()
+			// watch the directory, as editors replace the file rather than write to it
+			err = watcher.Add(filepath.Dir(c.path))
+			if err != nil {
+				slog.Error("failed to watch config file. Reload with SIGHUP instead", "path", c.path, "error", err)
+			}
+			fileEvents = watcher.Events
+		}
+	}
+
+	var pending <-chan time.Time
+	for {
+		select {
+		case <-hup:
+			slog.Info("received SIGHUP, reloading config")
+			c.reload()
+		case event, ok := <-fileEvents:
+			if !ok {
+				fileEvents = nil
+				continue
+			}
+			if filepath.Clean(event.Name) == filepath.Clean(c.path) && !event.Has(fsnotify.Chmod) {
+				pending = time.After(configReloadDelay)
+			}
+		case <-pending:
+			slog.Info("config file changed, reloading config", "path", c.path)
+			c.reload()
+		}
+	}
+}
+
+func (c *configReloader) reload() {
+	conf, warnings, err := application.LoadConfigWithOverrides(c.path, c.overrides)
+	for _, warning := range warnings {
+		slog.Warn("config warning", "warning", warning)
+	}
+	if err != nil {
+		slog.Error("invalid config, keeping the current config", "path", c.path, "error", err.Error())
+		return
+	}
+
+	changed := application.ChangedKeys(c.current, conf)
+	if len(changed) == 0 {
+		slog.Info("config unchanged")
+		return
+	}
+
+	// restart-only options keep their running values, so later reloads never apply them
+	live := make([]string, 0, len(changed))
+	libraryChanged := false
+	for _, key := range changed {
+		if !slices.Contains(liveConfigKeys, key) {
+			slog.Warn("config change requires a restart to take effect", "key", key)
+			continue
+		}
+		slog.Info("applying config change", "key", key)
+		live = append(live, key)
+		if !strings.HasPrefix(key, "gallery.") && !strings.HasPrefix(key, "log.") && !strings.HasPrefix(key, "slideshow.") {
+			libraryChanged = true
+		}
+	}
+	next := c.current
+	application.CopyOptions(&next, conf, live)
+
+	c.settings.Set(next.Gallery.Title, next.Gallery.Sort)
+	c.settings.SetPageSize(next.Gallery.PageSize)
+	c.settings.SetSlideshow(slideshowSettings(next))
+	c.logLevel.Set(next.LogLevel())
+	if libraryChanged {
+		for _, updates := range c.libraryUpdates {
+			sendLatest(updates, next)
+		}
+	}
+	c.current = next
+}
+
+// sendLatest queues conf on updates without blocking, replacing an update the library hasn't
+// applied yet. Libraries still loading or no longer watching would otherwise block the reloader.
+// updates must be buffered, and the reloader must be its only sender.
+func sendLatest(updates chan application.Config, conf application.Config) {
+	select {
+	case updates <- conf:
+	default:
+		select {
+		case <-updates:
+		default:
+		}
+		updates <- conf
+	}
+}
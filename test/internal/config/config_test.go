@@ -4,6 +4,8 @@ import (
 	"fotodeck/internal/application"
 	"fotodeck/internal/util"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -52,3 +54,383 @@ func TestValidateHomePathNonDir(t *testing.T) {
 
 	assert.ErrorContains(t, err, "home path is not a directory")
 }
+
+func writeConfig(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	err := os.WriteFile(path, []byte(contents), os.FileMode(0644))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigDefaults(t *testing.T) {
+	path := writeConfig(t, "[home]\npath = '/photos'\n")
+
+	config, err := application.LoadConfig(path)
+
+	assert.Nil(t, err)
+	defaults := application.DefaultConfig()
+	assert.Equal(t, "/photos", config.Home.Path)
+	assert.Equal(t, defaults.Home.MinRefreshInterval, config.Home.MinRefreshInterval)
+	assert.Equal(t, defaults.ImageResizing, config.ImageResizing)
+	assert.Equal(t, defaults.Server.ListenAddr, config.Server.ListenAddr)
+}
+
+func TestConfigValidation(t *testing.T) {
+	path := writeConfig(t, `
+[home]
+path = '/photos'
+minRefreshInterval = 0
+
+[imageResizing]
+resizedFileExtension = ''
+previewFileExtension = 'jpg'
+previewWidth = -1
+`)
+
+	_, err := application.LoadConfig(path)
+
+	assert.ErrorContains(t, err, "home.minRefreshInterval")
+	assert.ErrorContains(t, err, "imageResizing.resizedFileExtension: must not be empty")
+	assert.ErrorContains(t, err, "imageResizing.previewFileExtension: must not be an image file extension")
+	assert.ErrorContains(t, err, "imageResizing.previewWidth")
+}
+
+func TestConfigMissingHomePath(t *testing.T) {
+	path := writeConfig(t, "[server]\nlistenAddr = ':9000'\n")
+
+	_, err := application.LoadConfig(path)
+
+	assert.ErrorContains(t, err, "home.path")
+}
+
+func TestConfigUnknownKeys(t *testing.T) {
+	path := writeConfig(t, "[home]\npath = '/photos'\nminRefreshIntervall = 5\n\n[extra]\nkey = 1\n")
+
+	_, warnings, err := application.CheckConfig(path)
+
+	assert.Nil(t, err)
+	assert.Len(t, warnings, 2)
+	assert.Contains(t, warnings[0], "home.minRefreshIntervall")
+}
+
+func TestEnvOverridesOfArraySections(t *testing.T) {
+	env, warnings := application.EnvOverrides([]string{
+		"FOTODECK_LIBRARIES_0_PATH=/photos",
+		"FOTODECK_USERS_0_NAME=alice",
+	})
+
+	assert.Empty(t, env)
+	assert.Equal(t, []string{
+		"environment variable 'FOTODECK_LIBRARIES_0_PATH' will be ignored, [[libraries]] and [[users]] can only be set in the config file",
+		"environment variable 'FOTODECK_USERS_0_NAME' will be ignored, [[libraries]] and [[users]] can only be set in the config file",
+	}, warnings)
+}
+
+func TestConfigOverridePrecedence(t *testing.T) {
+	path := writeConfig(t, "[home]\npath = '/photos'\nminRefreshInterval = 20\n\n[server]\nlistenAddr = ':7000'\n")
+	env, warnings := application.EnvOverrides([]string{
+		"FOTODECK_HOME_MIN_REFRESH_INTERVAL=30",
+		"FOTODECK_SERVER_LISTEN_ADDR=:8000",
+		"FOTODECK_UNKNOWN=1",
+		"PATH=/usr/bin",
+	})
+	flags := map[string]string{"server.listenAddr": ":9000"}
+
+	config, _, err := application.LoadConfigWithOverrides(path, application.Overrides{Env: env, Flags: flags})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"unknown environment variable 'FOTODECK_UNKNOWN' will be ignored"}, warnings)
+	assert.Equal(t, 30, config.Home.MinRefreshInterval, "Environment should override the config file")
+	assert.Equal(t, ":9000", config.Server.ListenAddr, "Flags should override the environment")
+	assert.Equal(t, "/photos", config.Home.Path, "Config file should override defaults")
+}
+
+func TestConfigWithoutFile(t *testing.T) {
+	env, _ := application.EnvOverrides([]string{"FOTODECK_HOME_PATH=/photos"})
+
+	config, _, err := application.LoadConfigWithOverrides("", application.Overrides{Env: env})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/photos", config.Home.Path)
+	assert.Equal(t, application.DefaultConfig().Server, config.Server)
+}
+
+func TestConfigInvalidOverride(t *testing.T) {
+	flags := map[string]string{"home.path": "/photos", "imageResizing.verifyOnStartup": "maybe"}
+
+	_, _, err := application.LoadConfigWithOverrides("", application.Overrides{Flags: flags})
+
+	assert.ErrorContains(t, err, "flag imageResizing.verifyOnStartup: 'maybe' is not a boolean")
+}
+
+func TestPrintConfigRoundTrip(t *testing.T) {
+	original := util.Must(application.LoadConfig(configPath))
+	var out strings.Builder
+
+	err := application.PrintConfig(&out, original)
+	printed := util.Must(application.LoadConfig(writeConfig(t, out.String())))
+
+	assert.Nil(t, err)
+	assert.Equal(t, original, printed)
+}
+
+func TestConfigLibraries(t *testing.T) {
+	path := writeConfig(t, `
+[home]
+minRefreshInterval = 15
+
+[imageResizing]
+previewWidth = 500
+
+[[libraries]]
+name = 'family'
+path = '/mnt/family/'
+
+[[libraries]]
+name = 'work'
+path = '/mnt/work'
+minRefreshInterval = 60
+previewWidth = 200
+`)
+
+	config, err := application.LoadConfig(path)
+
+	assert.Nil(t, err)
+	libraries := config.EffectiveLibraries()
+	assert.Len(t, libraries, 2)
+	assert.Equal(t, "family", libraries[0].Name)
+	assert.Equal(t, "/mnt/family", libraries[0].Path)
+	assert.Equal(t, 15, libraries[0].MinRefreshInterval, "Refresh interval should be inherited from home")
+	assert.Equal(t, 500, libraries[0].ImageResizing.PreviewWidth, "Resize settings should be inherited")
+	assert.Equal(t, 60, libraries[1].MinRefreshInterval)
+	assert.Equal(t, 200, libraries[1].ImageResizing.PreviewWidth)
+	assert.Equal(t, config.ImageResizing.PreviewHeight, libraries[1].ImageResizing.PreviewHeight)
+}
+
+func TestConfigHomeIsDefaultLibrary(t *testing.T) {
+	config := util.Must(application.LoadConfig(configPath))
+
+	libraries := config.EffectiveLibraries()
+
+	assert.Len(t, libraries, 1)
+	assert.Equal(t, "", libraries[0].Name)
+	assert.Equal(t, config.Home.Path, libraries[0].Path)
+}
+
+func TestConfigLibraryValidation(t *testing.T) {
+	path := writeConfig(t, `
+[[libraries]]
+name = 'a b'
+path = '/mnt/a'
+
+[[libraries]]
+name = 'c'
+path = ''
+
+[[libraries]]
+name = 'c'
+path = '/mnt/c'
+previewWidth = -5
+`)
+
+	_, err := application.LoadConfig(path)
+
+	assert.ErrorContains(t, err, "libraries[0].name: must only contain letters")
+	assert.ErrorContains(t, err, "libraries[1].path")
+	assert.ErrorContains(t, err, "libraries[2].name: must be unique")
+	assert.ErrorContains(t, err, "libraries[2].previewWidth")
+	assert.NotContains(t, err.Error(), "home.path", "home.path should not be required when libraries are configured")
+}
+
+func TestConfigServerValidation(t *testing.T) {
+	path := writeConfig(t, `
+[home]
+path = '/photos'
+
+[server]
+writeTimeout = -1
+tlsCertFile = '/etc/fotodeck/cert.pem'
+`)
+
+	_, err := application.LoadConfig(path)
+
+	assert.ErrorContains(t, err, "server.writeTimeout")
+	assert.ErrorContains(t, err, "server.tlsKeyFile: must be set together with server.tlsCertFile")
+}
+
+func TestConfigUserValidation(t *testing.T) {
+	path := writeConfig(t, `
+[auth]
+required = true
+
+[[users]]
+name = 'alice'
+passwordHash = 'hunter2'
+
+[[libraries]]
+name = 'family'
+path = '/mnt/family'
+users = ['alice', 'bob']
+`)
+
+	_, err := application.LoadConfig(path)
+
+	assert.ErrorContains(t, err, "users[0].passwordHash: must be a bcrypt hash")
+	assert.NotContains(t, err.Error(), "hunter2", "Passwords should not be logged")
+	assert.ErrorContains(t, err, "libraries[0].users: must only contain users configured in [[users]] (got bob)")
+}
+
+func TestConfigAuthRequiresUsers(t *testing.T) {
+	path := writeConfig(t, `
+[home]
+path = '/photos'
+
+[auth]
+required = true
+`)
+
+	_, err := application.LoadConfig(path)
+
+	assert.ErrorContains(t, err, "auth.required")
+}
+
+func TestConfigLogValidation(t *testing.T) {
+	path := writeConfig(t, `
+[home]
+path = '/photos'
+
+[log]
+level = 'verbose'
+format = 'xml'
+
+[server]
+trustedProxies = ['10.0.0.0/8', '127.0.0.1', 'proxy.local']
+`)
+
+	_, err := application.LoadConfig(path)
+
+	assert.ErrorContains(t, err, "log.level")
+	assert.ErrorContains(t, err, "log.format")
+	assert.ErrorContains(t, err, "server.trustedProxies[2]")
+	assert.NotContains(t, err.Error(), "server.trustedProxies[0]")
+	assert.NotContains(t, err.Error(), "server.trustedProxies[1]")
+}
+
+func TestConfigWebValidation(t *testing.T) {
+	path := writeConfig(t, `
+[home]
+path = '/photos'
+
+[gallery]
+pageSize = -1
+
+[web]
+dev = true
+dir = ''
+`)
+
+	_, err := application.LoadConfig(path)
+
+	assert.ErrorContains(t, err, "gallery.pageSize")
+	assert.ErrorContains(t, err, "web.dir")
+}
+
+func TestConfigMapValidation(t *testing.T) {
+	path := writeConfig(t, `
+[home]
+path = '/photos'
+
+[map]
+tileUrl = 'http://localhost:8081/tiles/{z}/{x}.png'
+maxZoom = 30
+`)
+
+	_, err := application.LoadConfig(path)
+
+	assert.ErrorContains(t, err, "map.tileUrl")
+	assert.ErrorContains(t, err, "must contain {y}")
+	assert.ErrorContains(t, err, "map.maxZoom")
+}
+
+func TestConfigSlideshowValidation(t *testing.T) {
+	path := writeConfig(t, `
+[slideshow]
+interval = 0
+transition = 'spin'
+album = 'work'
+
+[[libraries]]
+name = 'family'
+path = '/mnt/family'
+`)
+
+	_, err := application.LoadConfig(path)
+
+	assert.ErrorContains(t, err, "slideshow.interval")
+	assert.ErrorContains(t, err, "slideshow.transition")
+	assert.ErrorContains(t, err, "slideshow.album")
+}
+
+func TestPrintConfigRedactsPasswordHashes(t *testing.T) {
+	hash := "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"
+	config := util.Must(application.LoadConfig(writeConfig(t, `
+[home]
+path = '/photos'
+
+[[users]]
+name = 'alice'
+passwordHash = '`+hash+`'
+`)))
+	var out strings.Builder
+
+	err := application.PrintConfig(&out, config)
+
+	assert.Nil(t, err)
+	assert.NotContains(t, out.String(), hash)
+	assert.Contains(t, out.String(), `passwordHash = "<redacted>"`)
+}
+
+func TestPrintConfigRedactsSharingSecret(t *testing.T) {
+	secret := "0123456789abcdef0123456789abcdef"
+	config := util.Must(application.LoadConfig(writeConfig(t, "[home]\npath = '/photos'\n\n[sharing]\nsecret = '"+secret+"'\n")))
+	var out strings.Builder
+
+	err := application.PrintConfig(&out, config)
+
+	assert.Nil(t, err)
+	assert.NotContains(t, out.String(), secret)
+	assert.Contains(t, out.String(), `secret = "<redacted>"`)
+}
+
+func TestResizeLoaderKeepsExtensions(t *testing.T) {
+	config := util.Must(application.LoadConfig(configPath))
+	library, _ := config.Library("")
+	loader := application.NewLoader(library)
+	library.ImageResizing.ResizedFileExtension = "big"
+	library.ImageResizing.PreviewFileExtension = "small"
+	library.ImageResizing.PreviewWidth = 123
+
+	application.ResizeLoader(&loader, library)
+
+	assert.Equal(t, "opt", loader.OptimisedExtension, "Extensions decide which files are originals and need a restart")
+	assert.Equal(t, "prev", loader.PreviewExtension)
+	assert.Equal(t, 123, loader.MaxPreviewDimensions.Width)
+}
+
+func TestCopyOptions(t *testing.T) {
+	running := util.Must(application.LoadConfig(configPath))
+	edited := running
+	edited.Gallery.Title = "Edited"
+	edited.ImageResizing.PreviewWidth = 123
+	edited.ImageResizing.PreviewFileExtension = "small"
+
+	next := running
+	application.CopyOptions(&next, edited, []string{"gallery.title", "imageResizing.previewWidth", "libraries"})
+
+	assert.Equal(t, "Edited", next.Gallery.Title)
+	assert.Equal(t, 123, next.ImageResizing.PreviewWidth)
+	assert.Equal(t, running.ImageResizing.PreviewFileExtension, next.ImageResizing.PreviewFileExtension, "Options not named should keep their values")
+	assert.Equal(t, []string{"imageResizing.previewFileExtension"}, application.ChangedKeys(next, edited), "Restart-only changes should still be pending")
+}
@@ -0,0 +1,79 @@
+package geo_test
+
+import (
+	"fotodeck/internal/geo"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// two photos in Lisbon a few hundred meters apart, one in Porto and one in Fiji east of the antimeridian
+var points = []geo.Point{
+	{ID: "alfama", Latitude: 38.7118, Longitude: -9.1300},
+	{ID: "baixa", Latitude: 38.7110, Longitude: -9.1366},
+	{ID: "porto", Latitude: 41.1496, Longitude: -8.6110},
+	{ID: "fiji", Latitude: -17.7134, Longitude: 178.0650},
+}
+
+func clusterIDs(clusters []geo.Cluster) [][]string {
+	ids := make([][]string, 0, len(clusters))
+	for _, cluster := range clusters {
+		ids = append(ids, cluster.IDs)
+	}
+	return ids
+}
+
+func TestClustersByZoom(t *testing.T) {
+	// when
+	world := geo.Clusters(points, geo.World, 0)
+	country := geo.Clusters(points, geo.World, 8)
+	street := geo.Clusters(points, geo.World, 17)
+
+	// then
+	assert.Equal(t, [][]string{{"alfama", "baixa", "porto"}, {"fiji"}}, clusterIDs(world))
+	assert.Equal(t, [][]string{{"alfama", "baixa"}, {"porto"}, {"fiji"}}, clusterIDs(country))
+	assert.Equal(t, [][]string{{"alfama"}, {"baixa"}, {"porto"}, {"fiji"}}, clusterIDs(street))
+}
+
+func TestClusterPosition(t *testing.T) {
+	// when
+	clusters := geo.Clusters(points[:2], geo.World, 8)
+
+	// then
+	assert.Len(t, clusters, 1)
+	assert.InDelta(t, 38.7114, clusters[0].Latitude, 0.00001)
+	assert.InDelta(t, -9.1333, clusters[0].Longitude, 0.00001)
+}
+
+func TestClustersWithinBBox(t *testing.T) {
+	// given
+	portugal := geo.BBox{West: -10, South: 36, East: -6, North: 42}
+	pacific := geo.BBox{West: 170, South: -30, East: -170, North: 0}
+
+	// when
+	inPortugal := geo.Clusters(points, portugal, 17)
+	inPacific := geo.Clusters(points, pacific, 17)
+
+	// then
+	assert.Equal(t, [][]string{{"alfama"}, {"baixa"}, {"porto"}}, clusterIDs(inPortugal))
+	assert.Equal(t, [][]string{{"fiji"}}, clusterIDs(inPacific), "boxes crossing the antimeridian should wrap")
+}
+
+func TestParseBBox(t *testing.T) {
+	// when
+	bbox, err := geo.ParseBBox("-10, 36, -6, 42")
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, geo.BBox{West: -10, South: 36, East: -6, North: 42}, bbox)
+}
+
+func TestParseBBoxInvalid(t *testing.T) {
+	for _, param := range []string{"", "1,2,3", "a,b,c,d", "0,50,10,40", "0,-100,10,40", "-200,0,10,40", "0,NaN,10,40"} {
+		// when
+		_, err := geo.ParseBBox(param)
+
+		// then
+		assert.NotNil(t, err, param)
+	}
+}
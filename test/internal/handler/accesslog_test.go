@@ -0,0 +1,93 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fotodeck/internal/handler"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLog(t *testing.T) {
+	// given
+	var buf bytes.Buffer
+	accessLog := handler.AccessLog{Logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+	h := accessLog.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	}))
+	req := httptest.NewRequest("GET", "http://mock/albums/a?sort=name", nil)
+	req.Header.Set("User-Agent", "test-agent")
+
+	// when
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	// then
+	var record map[string]any
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, "GET", record["method"])
+	assert.Equal(t, "/albums/a?sort=name", record["url"])
+	assert.Equal(t, float64(418), record["status"])
+	assert.Equal(t, float64(15), record["bytes"])
+	assert.Equal(t, "test-agent", record["userAgent"])
+	assert.Contains(t, record, "latency")
+}
+
+func TestAccessLogImagesAtDebug(t *testing.T) {
+	// given
+	var buf bytes.Buffer
+	accessLog := handler.AccessLog{Logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+	h := accessLog.Middleware(okHandler)
+
+	// when
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://mock/img/preview/a.jpg", nil))
+
+	// then
+	assert.Empty(t, buf.String(), "Successful image requests should be below the default Info level")
+}
+
+func TestClientIP(t *testing.T) {
+	// given
+	var buf bytes.Buffer
+	accessLog := handler.AccessLog{Logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+	clientIP := handler.ClientIP{TrustedProxies: []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("::1/128"),
+	}}
+	h := clientIP.Middleware(accessLog.Middleware(okHandler))
+
+	for _, tc := range []struct {
+		remoteAddr   string
+		forwardedFor string
+		expectedIP   string
+		reason       string
+	}{
+		{"10.0.0.1:1234", "203.0.113.7", "203.0.113.7", "trusted proxy"},
+		{"10.0.0.1:1234", "198.51.100.1, 203.0.113.7, 10.0.0.2", "203.0.113.7", "chain of trusted proxies"},
+		{"[::1]:1234", "203.0.113.7", "203.0.113.7", "trusted IPv6 proxy"},
+		{"192.0.2.1:1234", "203.0.113.7", "192.0.2.1", "untrusted client spoofing the header"},
+		{"10.0.0.1:1234", "garbage", "10.0.0.1", "malformed header"},
+		{"10.0.0.1:1234", "", "10.0.0.1", "no header"},
+	} {
+		buf.Reset()
+		req := httptest.NewRequest("GET", "http://mock/", nil)
+		req.RemoteAddr = tc.remoteAddr
+		if tc.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", tc.forwardedFor)
+		}
+
+		// when
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		// then
+		var record map[string]any
+		assert.Nil(t, json.Unmarshal(buf.Bytes(), &record))
+		assert.Equal(t, tc.expectedIP, record["remoteAddr"], tc.reason)
+	}
+}
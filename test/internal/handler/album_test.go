@@ -0,0 +1,196 @@
+package handler_test
+
+import (
+	"fotodeck/internal/handler"
+	"fotodeck/internal/images"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// albumHandler serves the albums family, with metadata, and private, which is hidden. The template
+// renders the title, description, photos and album list, separated by |
+func albumHandler(t *testing.T) handler.RootHandler {
+	fsys := fstest.MapFS{"template/index.html": {
+		Data: []byte(`{{.Title}}|{{.Description}}|{{range .Photos}}{{.ID}},{{end}}|{{range .AlbumList}}{{.Name}}={{.Title}}:{{.CoverURL}}:{{.Photos}},{{end}}`),
+	}}
+	templates, err := handler.NewTemplates(fsys, false)
+	assert.Nil(t, err)
+
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetLibrary("family", map[string]images.ImageFile{
+		"a.jpg": images.NewImageFile("a.jpg", "/family/a.jpg"),
+		"b.jpg": images.NewImageFile("b.jpg", "/family/b.jpg"),
+	}, false)
+	fileHolder.SetLibrary("private", map[string]images.ImageFile{
+		"c.jpg": images.NewImageFile("c.jpg", "/private/c.jpg"),
+	}, false)
+	fileHolder.SetAlbum("family", images.Album{Title: "Family", Description: "Holidays", Cover: "b.jpg", Sort: "name-desc"})
+	fileHolder.SetAlbum("private", images.Album{Hidden: true})
+
+	return handler.RootHandler{
+		FileHolder: &fileHolder,
+		Settings:   &handler.SiteSettings{Title: "Gallery", Sort: "name"},
+		Templates:  templates,
+	}
+}
+
+func TestAlbumListLeavesOutHiddenAlbums(t *testing.T) {
+	// given
+	rh := albumHandler(t)
+	w := httptest.NewRecorder()
+
+	// when
+	rh.Index(w, httptest.NewRequest("GET", "http://mock/", nil))
+
+	// then
+	assert.Equal(t, "Gallery||family:a.jpg,family:b.jpg,|family=Family:/img/preview/family:b.jpg:2,", w.Body.String())
+}
+
+func TestAlbumPageUsesAlbumFile(t *testing.T) {
+	// given
+	rh := albumHandler(t)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://mock/albums/family", nil)
+	r.SetPathValue("name", "family")
+
+	// when
+	rh.Album(w, r)
+
+	// then
+	assert.Equal(t, "Family|Holidays|family:b.jpg,family:a.jpg,|family=Family:/img/preview/family:b.jpg:2,", w.Body.String())
+}
+
+func TestHiddenAlbumReachableByURL(t *testing.T) {
+	// given
+	rh := albumHandler(t)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://mock/albums/private", nil)
+	r.SetPathValue("name", "private")
+
+	// when
+	rh.Album(w, r)
+
+	// then
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "private||private:c.jpg,|family=Family:/img/preview/family:b.jpg:2,", w.Body.String())
+}
+
+func TestAlbumCoverFallsBackToFirstPhoto(t *testing.T) {
+	// given
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetLibrary("family", map[string]images.ImageFile{
+		"b.jpg": images.NewImageFile("b.jpg", "/family/b.jpg"),
+		"a.jpg": images.NewImageFile("a.jpg", "/family/a.jpg"),
+	}, false)
+	fileHolder.SetAlbum("family", images.Album{Cover: "missing.jpg"})
+
+	// when
+	cover, photos := fileHolder.Cover("family")
+	emptyCover, _ := fileHolder.Cover("empty")
+
+	// then
+	assert.Equal(t, "family:a.jpg", cover)
+	assert.Equal(t, 2, photos)
+	assert.Equal(t, "", emptyCover)
+}
+
+// folderAlbumHolder has the home folder album :2021/trip and the hidden folder album family:hidden
+func folderAlbumHolder() *handler.FileHolder {
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetEntries(map[string]images.ImageFile{
+		"2021/trip/a.jpg":      images.NewImageFile("a.jpg", "/home/2021/trip/a.jpg"),
+		"2021/trip/day2/b.jpg": images.NewImageFile("b.jpg", "/home/2021/trip/day2/b.jpg"),
+		"c.jpg":                images.NewImageFile("c.jpg", "/home/c.jpg"),
+	}, false)
+	fileHolder.SetLibrary("family", map[string]images.ImageFile{
+		"x.jpg":        images.NewImageFile("x.jpg", "/family/x.jpg"),
+		"hidden/y.jpg": images.NewImageFile("y.jpg", "/family/hidden/y.jpg"),
+	}, false)
+	fileHolder.SetAlbums("", map[string]images.Album{"2021/trip": {Title: "Trip", Cover: "day2/b.jpg"}})
+	fileHolder.SetAlbums("family", map[string]images.Album{"": {Title: "Family"}, "hidden": {Hidden: true}})
+	return &fileHolder
+}
+
+func TestFolderAlbums(t *testing.T) {
+	// given
+	fileHolder := folderAlbumHolder()
+
+	// when
+	albums := fileHolder.Albums()
+	trip := fileHolder.AlbumFiles(":2021/trip")
+	cover, photos := fileHolder.Cover(":2021/trip")
+	gallery := fileHolder.GalleryFiles()
+	family := fileHolder.AlbumFiles("family")
+	hidden := fileHolder.AlbumFiles("family:hidden")
+
+	// then
+	assert.Equal(t, []string{":2021/trip", "family", "family:hidden"}, albums)
+	assert.ElementsMatch(t, []string{"2021/trip/a.jpg", "2021/trip/day2/b.jpg"}, trip, "folder albums should include their subfolders")
+	assert.Equal(t, "2021/trip/day2/b.jpg", cover, "the cover should be a path within the folder")
+	assert.Equal(t, 2, photos)
+	assert.ElementsMatch(t, []string{"2021/trip/a.jpg", "2021/trip/day2/b.jpg", "c.jpg", "family:x.jpg"}, gallery)
+	assert.Equal(t, []string{"family:x.jpg"}, family, "hidden folder albums should be left out of their parent album")
+	assert.Equal(t, []string{"family:hidden/y.jpg"}, hidden)
+}
+
+func TestFolderAlbumPage(t *testing.T) {
+	// given
+	rh := albumHandler(t)
+	rh.FileHolder = folderAlbumHolder()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://mock/albums/:2021%2Ftrip", nil)
+	r.SetPathValue("name", ":2021/trip")
+
+	// when
+	rh.Album(w, r)
+
+	// then
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "Trip||2021/trip/a.jpg,2021/trip/day2/b.jpg,|:2021/trip=Trip:/img/preview/2021%2Ftrip%2Fday2%2Fb.jpg:2,family=Family:/img/preview/family:x.jpg:1,", w.Body.String())
+}
+
+func TestSetAlbumsReplacesLibraryAlbums(t *testing.T) {
+	// given
+	fileHolder := folderAlbumHolder()
+
+	// when
+	fileHolder.SetAlbums("family", map[string]images.Album{"": {}})
+
+	// then
+	assert.Equal(t, []string{":2021/trip", "family"}, fileHolder.Albums())
+	assert.Contains(t, fileHolder.GalleryFiles(), "family:hidden/y.jpg", "photos of a removed hidden album should be shown again")
+	assert.Equal(t, "Trip", fileHolder.Album(":2021/trip").Title, "albums of other libraries should be kept")
+}
+
+func TestPhotoMetadata(t *testing.T) {
+	// given
+	fsys := fstest.MapFS{"template/index.html": {
+		Data: []byte(`{{range .Photos}}{{.ID}}:{{.Caption}}:{{join .Tags ","}}:{{.Rating}};{{end}}`),
+	}}
+	templates, err := handler.NewTemplates(fsys, false)
+	assert.Nil(t, err)
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetEntries(map[string]images.ImageFile{
+		"a.jpg": images.NewImageFile("a.jpg", "/a.jpg").WithMetadata(images.Metadata{
+			Caption: "Beach",
+			Tags:    []string{"sea", "summer"},
+			Rating:  4,
+		}),
+		"b.jpg": images.NewImageFile("b.jpg", "/b.jpg"),
+	}, false)
+	rh := handler.RootHandler{
+		FileHolder: &fileHolder,
+		Settings:   &handler.SiteSettings{Sort: "name"},
+		Templates:  templates,
+	}
+	w := httptest.NewRecorder()
+
+	// when
+	rh.Index(w, httptest.NewRequest("GET", "http://mock/", nil))
+
+	// then
+	assert.Equal(t, "a.jpg:Beach:sea,summer:4;b.jpg:::0;", w.Body.String())
+}
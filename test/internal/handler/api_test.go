@@ -0,0 +1,117 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fotodeck/internal/handler"
+	"fotodeck/internal/images"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serveAs requests target from h as user, through the auth middleware when auth is set.
+// user is logged in with the password of newAuth, or anonymous when empty.
+func serveAs(h http.HandlerFunc, auth *handler.Auth, target string, user string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "http://mock"+target, nil)
+	if user != "" {
+		req.SetBasicAuth(user, "secret")
+	}
+	w := httptest.NewRecorder()
+	if auth != nil {
+		auth.Middleware(h).ServeHTTP(w, req)
+	} else {
+		h(w, req)
+	}
+	return w
+}
+
+// getJSON requests target like serveAs, expecting a 200 response with a JSON body of type T
+func getJSON[T any](t *testing.T, h http.HandlerFunc, auth *handler.Auth, target string, user string) T {
+	w := serveAs(h, auth, target, user)
+	assert.Equal(t, http.StatusOK, w.Code, target)
+	var body T
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&body), target)
+	return body
+}
+
+// visiblePhotos counts the photos in the response to each of users with count, for checking that
+// endpoints leave out the albums a user can't view. Anonymous requests are keyed by ""
+func visiblePhotos[T any](t *testing.T, h http.HandlerFunc, auth *handler.Auth, target string, count func(T) int, users ...string) map[string]int {
+	counts := make(map[string]int, len(users))
+	for _, user := range users {
+		counts[user] = count(getJSON[T](t, h, auth, target, user))
+	}
+	return counts
+}
+
+// statusCodes requests path with each of queries anonymously, returning the status codes by query
+func statusCodes(h http.HandlerFunc, path string, queries ...string) map[string]int {
+	codes := make(map[string]int, len(queries))
+	for _, query := range queries {
+		codes[query] = serveAs(h, nil, path+query, "").Code
+	}
+	return codes
+}
+
+func TestDuplicatesApi(t *testing.T) {
+	files, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetEntries(files, false)
+	api := handler.ApiHandler{FileHolder: &fileHolder}
+	req := httptest.NewRequest("GET", "http://mock/api/duplicates", nil)
+	w := httptest.NewRecorder()
+
+	// when
+	api.Duplicates(w, req)
+
+	// then
+	resp := w.Result()
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	var groups []images.DuplicateGroup
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&groups))
+	assert.Empty(t, groups)
+}
+
+func TestSimilarApiNotFound(t *testing.T) {
+	files, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetEntries(files, false)
+	api := handler.ApiHandler{FileHolder: &fileHolder, SimilarityThreshold: 10}
+	req := httptest.NewRequest("GET", "http://mock/api/photos/mock.jpg/similar", nil)
+	req.SetPathValue("id", "mock.jpg")
+	w := httptest.NewRecorder()
+
+	// when
+	api.Similar(w, req)
+
+	// then
+	assert.Equal(t, 404, w.Result().StatusCode)
+}
+
+func TestSimilarApiBadDistance(t *testing.T) {
+	files, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetEntries(files, false)
+	api := handler.ApiHandler{FileHolder: &fileHolder, SimilarityThreshold: 10}
+	req := httptest.NewRequest("GET", "http://mock/api/photos/fire.jpg/similar?distance=abc", nil)
+	req.SetPathValue("id", "fire.jpg")
+	w := httptest.NewRecorder()
+
+	// when
+	api.Similar(w, req)
+
+	// then
+	assert.Equal(t, 400, w.Result().StatusCode)
+}
@@ -0,0 +1,166 @@
+package handler_test
+
+import (
+	"fotodeck/internal/handler"
+	"fotodeck/internal/images"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newAuth(t *testing.T, required bool) *handler.Auth {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	assert.Nil(t, err)
+	return &handler.Auth{
+		Users: map[string]string{
+			"alice": string(hash),
+			"bob":   string(hash),
+		},
+		LibraryUsers:    map[string][]string{"private": {"alice"}},
+		Required:        required,
+		SessionLifetime: time.Hour,
+	}
+}
+
+// userHandler responds with the authenticated user
+var userHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(handler.UserFromRequest(r)))
+})
+
+func TestAuthBasic(t *testing.T) {
+	// given
+	auth := newAuth(t, true)
+	req := httptest.NewRequest("GET", "http://mock/img/a.jpg", nil)
+	req.SetBasicAuth("alice", "secret")
+	w := httptest.NewRecorder()
+
+	// when
+	auth.Middleware(userHandler).ServeHTTP(w, req)
+
+	// then
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "alice", w.Body.String())
+}
+
+func TestAuthBasicWrongPassword(t *testing.T) {
+	// given
+	auth := newAuth(t, false)
+	for _, user := range []string{"alice", "mallory"} {
+		req := httptest.NewRequest("GET", "http://mock/api/duplicates", nil)
+		req.SetBasicAuth(user, "wrong")
+		w := httptest.NewRecorder()
+
+		// when
+		auth.Middleware(userHandler).ServeHTTP(w, req)
+
+		// then
+		assert.Equal(t, 401, w.Code, user)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Basic")
+	}
+}
+
+func TestAuthRequired(t *testing.T) {
+	// given
+	auth := newAuth(t, true)
+
+	// when
+	page := httptest.NewRecorder()
+	auth.Middleware(userHandler).ServeHTTP(page, httptest.NewRequest("GET", "http://mock/albums/a?x=1", nil))
+	img := httptest.NewRecorder()
+	auth.Middleware(userHandler).ServeHTTP(img, httptest.NewRequest("GET", "http://mock/img/a.jpg", nil))
+	public := httptest.NewRecorder()
+	auth.Middleware(userHandler).ServeHTTP(public, httptest.NewRequest("GET", "http://mock/public/index.css", nil))
+
+	// then
+	assert.Equal(t, 303, page.Code, "Browsers should be sent to the login page")
+	assert.Equal(t, "/login?next="+url.QueryEscape("/albums/a?x=1"), page.Header().Get("Location"))
+	assert.Equal(t, 401, img.Code)
+	assert.Equal(t, 200, public.Code, "Static files should not require a login")
+}
+
+func TestAuthNotRequired(t *testing.T) {
+	// given
+	auth := newAuth(t, false)
+	w := httptest.NewRecorder()
+
+	// when
+	auth.Middleware(userHandler).ServeHTTP(w, httptest.NewRequest("GET", "http://mock/", nil))
+
+	// then
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "", w.Body.String())
+}
+
+func TestAuthLoginSession(t *testing.T) {
+	// given
+	auth := newAuth(t, true)
+	form := url.Values{"user": {"alice"}, "password": {"secret"}, "next": {"//evil.example"}}
+	req := httptest.NewRequest("POST", "http://mock/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	login := httptest.NewRecorder()
+
+	// when
+	auth.Middleware(http.HandlerFunc(auth.Login)).ServeHTTP(login, req)
+
+	// then
+	assert.Equal(t, 303, login.Code)
+	assert.Equal(t, "/", login.Header().Get("Location"), "Redirects off site should be ignored")
+	cookies := login.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	assert.True(t, cookies[0].HttpOnly)
+
+	// when
+	req = httptest.NewRequest("GET", "http://mock/", nil)
+	req.AddCookie(cookies[0])
+	w := httptest.NewRecorder()
+	auth.Middleware(userHandler).ServeHTTP(w, req)
+
+	// then
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "alice", w.Body.String())
+
+	// when
+	req = httptest.NewRequest("POST", "http://mock/logout", nil)
+	req.AddCookie(cookies[0])
+	auth.Middleware(http.HandlerFunc(auth.Logout)).ServeHTTP(httptest.NewRecorder(), req)
+	req = httptest.NewRequest("GET", "http://mock/", nil)
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	auth.Middleware(userHandler).ServeHTTP(w, req)
+
+	// then
+	assert.Equal(t, 303, w.Code, "Session should end on logout")
+}
+
+func TestAuthLibraryAccess(t *testing.T) {
+	files, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetLibrary("private", files, false)
+	auth := newAuth(t, false)
+	imageHandler := handler.ImageHandler{FileHolder: &fileHolder, Auth: auth}
+	id := images.LibraryID("private", "fire.jpg")
+
+	for user, status := range map[string]int{"alice": 200, "bob": 404, "": 404} {
+		req := httptest.NewRequest("GET", "http://mock/img/"+id, nil)
+		req.SetPathValue("id", id)
+		if user != "" {
+			req.SetBasicAuth(user, "secret")
+		}
+		w := httptest.NewRecorder()
+
+		// when
+		auth.Middleware(http.HandlerFunc(imageHandler.Images)).ServeHTTP(w, req)
+
+		// then
+		assert.Equal(t, status, w.Code, "user %q", user)
+	}
+}
@@ -0,0 +1,116 @@
+package handler_test
+
+import (
+	"fotodeck/internal/handler"
+	"fotodeck/internal/images"
+	"fotodeck/web"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// geoFileHolder has two photos in Lisbon, one in Porto, one without a location and one in a hidden album
+func geoFileHolder() *handler.FileHolder {
+	located := func(name string, day int, location *images.Location) images.ImageFile {
+		taken := time.Date(2023, 5, day, 12, 0, 0, 0, time.UTC)
+		return images.NewImageFile(name, "/"+name).WithMetadata(images.Metadata{Taken: taken, Location: location})
+	}
+	lisbon := &images.Location{Latitude: 38.7118, Longitude: -9.1300}
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetLibrary("trip", map[string]images.ImageFile{
+		"alfama.jpg": located("alfama.jpg", 1, lisbon),
+		"baixa.jpg":  located("baixa.jpg", 2, &images.Location{Latitude: 38.7110, Longitude: -9.1366}),
+		"porto.jpg":  located("porto.jpg", 3, &images.Location{Latitude: 41.1496, Longitude: -8.6110}),
+		"hotel.jpg":  located("hotel.jpg", 4, nil),
+	}, false)
+	fileHolder.SetLibrary("archive", map[string]images.ImageFile{
+		"old.jpg": located("old.jpg", 5, lisbon),
+	}, false)
+	fileHolder.SetAlbum("archive", images.Album{Hidden: true})
+	return &fileHolder
+}
+
+func TestGeoApi(t *testing.T) {
+	// given
+	api := handler.ApiHandler{FileHolder: geoFileHolder()}
+
+	// when
+	country := getJSON[handler.GeoJSON](t, api.Geo, nil, "/api/geo?zoom=8", "")
+	street := getJSON[handler.GeoJSON](t, api.Geo, nil, "/api/geo?zoom=17&bbox=-9.2,38.7,-9.1,38.8", "")
+
+	// then
+	assert.Equal(t, "FeatureCollection", country.Type)
+	assert.Len(t, country.Features, 2, "photos without a location or in hidden albums should be left out")
+	assert.Equal(t, "trip:porto.jpg", country.Features[0].Properties.Photo.ID, "clusters should be ordered by their newest photo")
+	lisbon := country.Features[1]
+	assert.Equal(t, "Feature", lisbon.Type)
+	assert.Equal(t, "Point", lisbon.Geometry.Type)
+	assert.InDelta(t, -9.1333, lisbon.Geometry.Coordinates[0], 0.0001)
+	assert.InDelta(t, 38.7114, lisbon.Geometry.Coordinates[1], 0.0001)
+	assert.Equal(t, 2, lisbon.Properties.Count)
+	assert.Equal(t, "trip:baixa.jpg", lisbon.Properties.Photo.ID, "clusters should show their newest photo")
+	assert.Equal(t, "/img/preview/trip:baixa.jpg", lisbon.Properties.Photo.PreviewURL)
+
+	assert.Len(t, street.Features, 2)
+	assert.Equal(t, 1, street.Features[0].Properties.Count)
+}
+
+func TestGeoApiRestricted(t *testing.T) {
+	// given
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetLibrary("private", map[string]images.ImageFile{
+		"c.jpg": images.NewImageFile("c.jpg", "/private/c.jpg").WithMetadata(images.Metadata{Location: &images.Location{Latitude: 1, Longitude: 1}}),
+	}, false)
+	api := handler.ApiHandler{FileHolder: &fileHolder, Auth: newAuth(t, false)}
+	count := func(collection handler.GeoJSON) int {
+		return len(collection.Features)
+	}
+
+	// when
+	features := visiblePhotos(t, api.Geo, api.Auth, "/api/geo", count, "", "alice")
+
+	// then
+	assert.Equal(t, map[string]int{"": 0, "alice": 1}, features)
+}
+
+func TestGeoApiInvalidParams(t *testing.T) {
+	// given
+	api := handler.ApiHandler{FileHolder: geoFileHolder()}
+
+	// when
+	codes := statusCodes(api.Geo, "/api/geo", "?zoom=-1", "?zoom=23", "?zoom=x", "?bbox=1,2,3", "?bbox=0,50,10,40")
+
+	// then
+	for query, code := range codes {
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
+
+func TestRenderEmbeddedMap(t *testing.T) {
+	// given
+	templates, err := handler.NewTemplates(web.Files, false)
+	assert.Nil(t, err)
+	rh := handler.RootHandler{
+		FileHolder: geoFileHolder(),
+		Settings:   &handler.SiteSettings{Title: "Embedded"},
+		Templates:  templates,
+		MapSettings: handler.MapSettings{
+			TileURL:     "http://tiles.local/{z}/{x}/{y}.png",
+			Attribution: "Local tiles",
+			MaxZoom:     16,
+		},
+	}
+	w := httptest.NewRecorder()
+
+	// when
+	rh.Map(w, httptest.NewRequest("GET", "http://mock/map", nil))
+
+	// then
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `data-tiles="http://tiles.local/{z}/{x}/{y}.png"`)
+	assert.Contains(t, w.Body.String(), `data-max-zoom="16"`)
+	assert.Contains(t, w.Body.String(), "Local tiles")
+}
@@ -0,0 +1,94 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fotodeck/internal/handler"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readyz(t *testing.T, health *handler.Health) (int, handler.ReadinessResponse) {
+	w := httptest.NewRecorder()
+	health.Readyz(w, httptest.NewRequest("GET", "http://mock/readyz", nil))
+	var resp handler.ReadinessResponse
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&resp))
+	return w.Code, resp
+}
+
+func TestHealthz(t *testing.T) {
+	// given
+	health := handler.Health{}
+	w := httptest.NewRecorder()
+
+	// when
+	health.Healthz(w, httptest.NewRequest("GET", "http://mock/healthz", nil))
+
+	// then
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"status": "ok"}`, w.Body.String())
+}
+
+func TestReadyzLoading(t *testing.T) {
+	// given
+	health := handler.Health{}
+	health.AddLibrary("", t.TempDir())
+
+	// when
+	code, resp := readyz(t, &health)
+
+	// then
+	assert.Equal(t, 503, code, "Should not be ready until the catalog is loaded")
+	assert.Equal(t, handler.StatusNotReady, resp.Status)
+	assert.Equal(t, "home", resp.Libraries[0].Name)
+	assert.False(t, resp.Libraries[0].Loaded)
+}
+
+func TestReadyzReady(t *testing.T) {
+	// given
+	health := handler.Health{}
+	health.AddLibrary("family", t.TempDir())
+	health.SetLoaded("family")
+	health.SetWatching("family", true, nil)
+
+	// when
+	code, resp := readyz(t, &health)
+
+	// then
+	assert.Equal(t, 200, code)
+	assert.Equal(t, handler.StatusReady, resp.Status)
+	assert.Equal(t, []handler.LibraryHealth{{Name: "family", Loaded: true, Accessible: true, Watching: true}}, resp.Libraries)
+}
+
+func TestReadyzDegradedWithoutWatcher(t *testing.T) {
+	// given
+	health := handler.Health{}
+	health.AddLibrary("family", t.TempDir())
+	health.SetLoaded("family")
+	health.SetWatching("family", false, errors.New("too many open files"))
+
+	// when
+	code, resp := readyz(t, &health)
+
+	// then
+	assert.Equal(t, 200, code, "Photos are still served without a watcher")
+	assert.Equal(t, handler.StatusDegraded, resp.Status)
+	assert.Equal(t, "too many open files", resp.Libraries[0].WatcherError)
+}
+
+func TestReadyzInaccessiblePath(t *testing.T) {
+	// given
+	health := handler.Health{}
+	health.AddLibrary("family", "./does-not-exist")
+	health.SetLoaded("family")
+	health.SetWatching("family", true, nil)
+
+	// when
+	code, resp := readyz(t, &health)
+
+	// then
+	assert.Equal(t, 503, code)
+	assert.False(t, resp.Libraries[0].Accessible)
+}
@@ -45,14 +45,20 @@ func setupTest(t *testing.T) (map[string]images.ImageFile, func(t *testing.T)) {
 		}
 }
 
+func newImageHandler(files map[string]images.ImageFile) handler.ImageHandler {
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetEntries(files, false)
+	return handler.ImageHandler{
+		FileHolder: &fileHolder,
+	}
+}
+
 func TestImageHandler(t *testing.T) {
 	files, teardown := setupTest(t)
 	defer teardown(t)
 
 	// given
-	handler := handler.ImageHandler{
-		FileEntries: files,
-	}
+	handler := newImageHandler(files)
 	req := httptest.NewRequest("GET", "http://mock", nil)
 	req.SetPathValue("id", "ambience.jpg")
 	w := httptest.NewRecorder()
@@ -73,9 +79,7 @@ func TestImageHandlerNotFound(t *testing.T) {
 	defer teardown(t)
 
 	// given
-	handler := handler.ImageHandler{
-		FileEntries: files,
-	}
+	handler := newImageHandler(files)
 	req := httptest.NewRequest("GET", "http://mock", nil)
 	req.SetPathValue("id", "mock.jpg")
 	w := httptest.NewRecorder()
@@ -93,9 +97,7 @@ func TestPreviewHandler(t *testing.T) {
 	defer teardown(t)
 
 	// given
-	handler := handler.ImageHandler{
-		FileEntries: files,
-	}
+	handler := newImageHandler(files)
 	req := httptest.NewRequest("GET", "http://mock", nil)
 	req.SetPathValue("id", "fire.jpg")
 	w := httptest.NewRecorder()
@@ -116,9 +118,7 @@ func TestPreviewHandlerNotFound(t *testing.T) {
 	defer teardown(t)
 
 	// given
-	handler := handler.ImageHandler{
-		FileEntries: files,
-	}
+	handler := newImageHandler(files)
 	req := httptest.NewRequest("GET", "http://mock", nil)
 	req.SetPathValue("id", "mock-preview.jpg")
 	w := httptest.NewRecorder()
@@ -130,3 +130,31 @@ func TestPreviewHandlerNotFound(t *testing.T) {
 	resp := w.Result()
 	assert.Equal(t, 404, resp.StatusCode)
 }
+
+func TestImageHandlerSvg(t *testing.T) {
+	_, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"><script>alert(2)</script><circle r="2"/></svg>`
+	err := os.WriteFile(homePath+"/vector.svg", []byte(svg), os.FileMode(0644))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := newImageHandler(map[string]images.ImageFile{
+		"vector.svg": images.NewImageFile("vector.svg", homePath+"/vector.svg"),
+	})
+	req := httptest.NewRequest("GET", "http://mock", nil)
+	req.SetPathValue("id", "vector.svg")
+	w := httptest.NewRecorder()
+
+	// when
+	handler.Previews(w, req)
+
+	// then
+	resp := w.Result()
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "image/svg+xml", resp.Header.Get("Content-Type"))
+	assert.NotEmpty(t, resp.Header.Get("Content-Security-Policy"))
+	assert.NotContains(t, w.Body.String(), "alert")
+}
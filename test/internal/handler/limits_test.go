@@ -0,0 +1,118 @@
+package handler_test
+
+import (
+	"fotodeck/internal/handler"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func requestFrom(remoteAddr string) *http.Request {
+	req := httptest.NewRequest("GET", "http://mock/img/a.jpg", nil)
+	req.RemoteAddr = remoteAddr
+	return req
+}
+
+func TestRateLimiter(t *testing.T) {
+	// given
+	limiter := handler.RateLimiter{Rate: 0.5, Burst: 3}
+	h := limiter.Middleware(okHandler)
+
+	for i := 0; i < 3; i++ {
+		// when
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, requestFrom("10.0.0.1:1234"))
+
+		// then
+		assert.Equal(t, 200, w.Code, "Requests within the burst should be allowed")
+	}
+
+	// when
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, requestFrom("10.0.0.1:5678"))
+
+	// then
+	assert.Equal(t, 429, w.Code, "Requests over the burst should be limited, whatever the port")
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, retryAfter, "Retry-After should be the seconds until the next token")
+}
+
+func TestRateLimiterPerClient(t *testing.T) {
+	// given
+	limiter := handler.RateLimiter{Rate: 1, Burst: 1}
+	h := limiter.Middleware(okHandler)
+	h.ServeHTTP(httptest.NewRecorder(), requestFrom("10.0.0.1:1234"))
+
+	// when
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, requestFrom("10.0.0.2:1234"))
+
+	// then
+	assert.Equal(t, 200, w.Code, "Clients should have their own bucket")
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	// given
+	limiter := handler.NewConcurrencyLimiter(2)
+	started := sync.WaitGroup{}
+	started.Add(2)
+	release := make(chan struct{})
+	blocking := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started.Done()
+		<-release
+	}))
+
+	done := sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			blocking.ServeHTTP(httptest.NewRecorder(), requestFrom("10.0.0.1:1234"))
+		}()
+	}
+	started.Wait()
+
+	// when
+	w := httptest.NewRecorder()
+	blocking.ServeHTTP(w, requestFrom("10.0.0.2:1234"))
+
+	// then
+	assert.Equal(t, 429, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// when
+	close(release)
+	done.Wait()
+	w = httptest.NewRecorder()
+	limiter.Middleware(okHandler).ServeHTTP(w, requestFrom("10.0.0.2:1234"))
+
+	// then
+	assert.Equal(t, 200, w.Code, "Slots should be released when requests finish")
+}
+
+func TestLimitBodySize(t *testing.T) {
+	// given
+	var readErr error
+	h := handler.LimitBodySize(10, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+	req := httptest.NewRequest("POST", "http://mock/api/share", strings.NewReader(strings.Repeat("x", 11)))
+
+	// when
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	// then
+	var maxBytesErr *http.MaxBytesError
+	assert.ErrorAs(t, readErr, &maxBytesErr)
+}
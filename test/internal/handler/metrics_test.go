@@ -0,0 +1,55 @@
+package handler_test
+
+import (
+	"fotodeck/internal/handler"
+	"fotodeck/internal/metrics"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrument(t *testing.T) {
+	// given
+	route := "/test/instrument/{id}"
+	h := handler.Instrument(route, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("missing"))
+	}))
+
+	// when
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://mock/test/instrument/1", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://mock/test/instrument/2", nil))
+
+	// then
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.HttpRequests.WithLabelValues(route, "GET", "404")))
+	assert.Equal(t, float64(14), testutil.ToFloat64(metrics.HttpResponseBytes.WithLabelValues(route)))
+}
+
+func TestCatalogMetric(t *testing.T) {
+	files, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	fileHolder := handler.FileHolder{}
+
+	// when
+	fileHolder.SetLibrary("metrics", files, false)
+
+	// then
+	assert.Equal(t, float64(len(files)), testutil.ToFloat64(metrics.CatalogPhotos.WithLabelValues("metrics")))
+}
+
+func TestMetricsHandler(t *testing.T) {
+	// given
+	w := httptest.NewRecorder()
+
+	// when
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest("GET", "http://mock/metrics", nil))
+
+	// then
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "fotodeck_worker_queue_depth")
+}
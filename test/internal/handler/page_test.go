@@ -0,0 +1,103 @@
+package handler_test
+
+import (
+	"fotodeck/internal/handler"
+	"html"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pageHandler renders the photo IDs of the page, then the page links, separated by |
+func pageHandler(t *testing.T, sort string, pageSize int) handler.RootHandler {
+	files, teardown := setupTest(t)
+	t.Cleanup(func() { teardown(t) })
+
+	fsys := fstest.MapFS{"template/index.html": {
+		Data: []byte(`{{range .Photos}}{{.ID}},{{end}}|{{.Page.Number}}/{{.Page.Count}}|{{.Page.PrevURL}}|{{.Page.NextURL}}`),
+	}}
+	templates, err := handler.NewTemplates(fsys, false)
+	assert.Nil(t, err)
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetEntries(files, false)
+	return handler.RootHandler{
+		FileHolder: &fileHolder,
+		Settings:   &handler.SiteSettings{Sort: sort, PageSize: pageSize},
+		Templates:  templates,
+	}
+}
+
+func getPage(rh handler.RootHandler, query string) []string {
+	w := httptest.NewRecorder()
+	rh.Index(w, httptest.NewRequest("GET", "http://mock/"+query, nil))
+	// links are HTML escaped, as a browser would see them
+	return strings.Split(html.UnescapeString(w.Body.String()), "|")
+}
+
+func TestPagination(t *testing.T) {
+	// given
+	rh := pageHandler(t, "name", 1)
+
+	// when
+	first := getPage(rh, "")
+	second := getPage(rh, first[3])
+
+	// then
+	assert.Equal(t, []string{"ambience.jpg,", "1/2", "", "?page=2"}, first)
+	assert.Equal(t, []string{"fire.jpg,", "2/2", "?page=1", ""}, second)
+}
+
+func TestPaginationOutOfRange(t *testing.T) {
+	// given
+	rh := pageHandler(t, "name", 1)
+
+	// when
+	page := getPage(rh, "?page=9")
+
+	// then
+	assert.Equal(t, "2/2", page[1], "pages past the end should show the last page")
+}
+
+func TestPaginationDisabled(t *testing.T) {
+	// given
+	rh := pageHandler(t, "name", 0)
+
+	// when
+	page := getPage(rh, "?page=2")
+
+	// then
+	assert.Equal(t, []string{"ambience.jpg,fire.jpg,", "1/1", "", ""}, page)
+}
+
+func TestPaginationRandomOrderIsStable(t *testing.T) {
+	// given
+	rh := pageHandler(t, "random", 1)
+
+	for i := 0; i < 10; i++ {
+		// when
+		first := getPage(rh, "")
+		second := getPage(rh, first[3])
+
+		// then
+		assert.Contains(t, first[3], "seed=")
+		assert.NotEqual(t, first[0], second[0], "the next page should not repeat a photo")
+		assert.Equal(t, second, getPage(rh, first[3]), "the same page link should show the same photos")
+	}
+}
+
+func TestPaginationKeepsSearch(t *testing.T) {
+	// given
+	rh := pageHandler(t, "name", 1)
+	w := httptest.NewRecorder()
+
+	// when
+	rh.Search(w, httptest.NewRequest("GET", "http://mock/search?q=jpg", nil))
+
+	// then
+	page := strings.Split(html.UnescapeString(w.Body.String()), "|")
+	assert.Equal(t, "1/2", page[1])
+	assert.Equal(t, "?page=2&q=jpg", page[3], "page links should keep the search")
+}
@@ -0,0 +1,77 @@
+package handler_test
+
+import (
+	"fotodeck/internal/handler"
+	"fotodeck/internal/images"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileHolderLibraries(t *testing.T) {
+	// given
+	fileHolder := handler.FileHolder{}
+	family := map[string]images.ImageFile{
+		"a.jpg": images.NewImageFile("a.jpg", "/family/a.jpg"),
+		"b.jpg": images.NewImageFile("b.jpg", "/family/b.jpg"),
+	}
+	work := map[string]images.ImageFile{
+		"a.jpg": images.NewImageFile("a.jpg", "/work/a.jpg"),
+	}
+
+	// when
+	fileHolder.SetLibrary("family", family, false)
+	fileHolder.SetLibrary("work", work, false)
+
+	// then
+	files := slices.Clone(fileHolder.Files)
+	slices.Sort(files)
+	assert.Equal(t, []string{"family:a.jpg", "family:b.jpg", "work:a.jpg"}, files)
+	assert.Equal(t, []string{"family", "work"}, fileHolder.Libraries())
+	assert.Equal(t, []string{"work:a.jpg"}, fileHolder.LibraryFiles("work"))
+
+	entry, ok := fileHolder.Get("work:a.jpg")
+	assert.True(t, ok)
+	assert.Equal(t, "/work/a.jpg", entry.GetFullSize())
+	_, ok = fileHolder.Get("a.jpg")
+	assert.False(t, ok, "IDs should be namespaced by library")
+}
+
+func TestFileHolderHomeNameWithColon(t *testing.T) {
+	// given
+	fileHolder := handler.FileHolder{}
+	home := map[string]images.ImageFile{
+		"2024:trip/a.jpg": images.NewImageFile("a.jpg", "/home/2024:trip/a.jpg"),
+	}
+
+	// when
+	fileHolder.SetEntries(home, false)
+	library, name := images.SplitLibraryID(fileHolder.Files[0])
+
+	// then
+	assert.Equal(t, []string{":2024:trip/a.jpg"}, fileHolder.Files)
+	assert.Equal(t, "", library, "a home name containing : should not be read as a library")
+	assert.Equal(t, "2024:trip/a.jpg", name)
+	assert.Equal(t, []string{":2024:trip/a.jpg"}, fileHolder.LibraryFiles(""))
+	entry, ok := fileHolder.Get(":2024:trip/a.jpg")
+	assert.True(t, ok)
+	assert.Equal(t, "/home/2024:trip/a.jpg", entry.GetFullSize())
+}
+
+func TestFileHolderReplaceLibrary(t *testing.T) {
+	// given
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetLibrary("family", map[string]images.ImageFile{
+		"a.jpg": images.NewImageFile("a.jpg", "/family/a.jpg"),
+	}, false)
+	fileHolder.SetLibrary("work", map[string]images.ImageFile{
+		"b.jpg": images.NewImageFile("b.jpg", "/work/b.jpg"),
+	}, false)
+
+	// when
+	fileHolder.SetLibrary("family", map[string]images.ImageFile{}, false)
+
+	// then
+	assert.Equal(t, []string{"work:b.jpg"}, fileHolder.Files)
+}
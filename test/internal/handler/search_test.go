@@ -0,0 +1,126 @@
+package handler_test
+
+import (
+	"fotodeck/internal/handler"
+	"fotodeck/internal/images"
+	"fotodeck/internal/search"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// searchFileHolder has a beach photo in each of family, private (restricted to alice) and archive (hidden)
+func searchFileHolder() *handler.FileHolder {
+	beach := images.Metadata{Caption: "Beach day", Tags: []string{"beach"}}
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetLibrary("family", map[string]images.ImageFile{
+		"a.jpg": images.NewImageFile("a.jpg", "/family/a.jpg").WithMetadata(beach),
+		"b.jpg": images.NewImageFile("b.jpg", "/family/b.jpg").WithMetadata(images.Metadata{Caption: "Campfire"}),
+	}, false)
+	fileHolder.SetLibrary("private", map[string]images.ImageFile{
+		"c.jpg": images.NewImageFile("c.jpg", "/private/c.jpg").WithMetadata(beach),
+	}, false)
+	fileHolder.SetLibrary("archive", map[string]images.ImageFile{
+		"d.jpg": images.NewImageFile("d.jpg", "/archive/d.jpg").WithMetadata(beach),
+	}, false)
+	fileHolder.SetAlbum("archive", images.Album{Hidden: true})
+	return &fileHolder
+}
+
+func TestSearchApi(t *testing.T) {
+	// given
+	api := handler.ApiHandler{FileHolder: searchFileHolder(), Auth: newAuth(t, false)}
+
+	// when
+	anonymous := getJSON[handler.SearchResponse](t, api.Search, api.Auth, "/api/search?q=beach", "")
+	alice := getJSON[handler.SearchResponse](t, api.Search, api.Auth, "/api/search?q=beach", "alice")
+	hidden := getJSON[handler.SearchResponse](t, api.Search, api.Auth, "/api/search?q=beach&album=archive", "")
+
+	// then
+	assert.Equal(t, 1, anonymous.Total)
+	assert.Equal(t, "family:a.jpg", anonymous.Photos[0].ID)
+	assert.Equal(t, "Beach day", anonymous.Photos[0].Caption)
+	assert.Equal(t, "/img/preview/family:a.jpg", anonymous.Photos[0].PreviewURL)
+	assert.Equal(t, []search.FacetCount{{Value: "beach", Count: 1}}, anonymous.Facets[search.FacetTag])
+
+	assert.Equal(t, 2, alice.Total, "restricted albums should only be searched by their users")
+	assert.Equal(t, 1, hidden.Total, "hidden albums should be found when filtering by them")
+	assert.Equal(t, "archive:d.jpg", hidden.Photos[0].ID)
+}
+
+func TestSearchApiPaging(t *testing.T) {
+	// given
+	api := handler.ApiHandler{FileHolder: searchFileHolder(), Auth: newAuth(t, false)}
+
+	// when
+	first := getJSON[handler.SearchResponse](t, api.Search, api.Auth, "/api/search?limit=1", "")
+	second := getJSON[handler.SearchResponse](t, api.Search, api.Auth, "/api/search?limit=1&offset=1", "")
+	past := getJSON[handler.SearchResponse](t, api.Search, api.Auth, "/api/search?offset=10", "")
+
+	// then
+	assert.Equal(t, 2, first.Total)
+	assert.Len(t, first.Photos, 1)
+	assert.Len(t, second.Photos, 1)
+	assert.NotEqual(t, first.Photos[0].ID, second.Photos[0].ID)
+	assert.Empty(t, past.Photos)
+}
+
+func TestSearchApiInvalidParams(t *testing.T) {
+	// given
+	api := handler.ApiHandler{FileHolder: searchFileHolder()}
+
+	// when
+	codes := statusCodes(api.Search, "/api/search", "?limit=0", "?limit=5000", "?limit=x", "?offset=-1")
+
+	// then
+	for query, code := range codes {
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
+
+func TestSearchPageFacets(t *testing.T) {
+	// given
+	fsys := fstest.MapFS{"template/index.html": {
+		Data: []byte(`{{.Query}}|{{range .Photos}}{{.ID}},{{end}}|{{range .Facets}}{{.Name}}:{{range .Values}}{{.Value}}={{.Count}},{{.Selected}},{{.URL}};{{end}}{{end}}`),
+	}}
+	templates, err := handler.NewTemplates(fsys, false)
+	assert.Nil(t, err)
+	rh := handler.RootHandler{
+		FileHolder: searchFileHolder(),
+		Settings:   &handler.SiteSettings{},
+		Templates:  templates,
+	}
+	w := httptest.NewRecorder()
+
+	// when
+	rh.Search(w, httptest.NewRequest("GET", "http://mock/search?q=beach&tag=beach&page=2", nil))
+
+	// then
+	assert.Equal(t, "beach|family:a.jpg,private:c.jpg,|"+
+		"tag:beach=2,true,/search?q=beach;"+
+		"album:family=1,false,/search?album=family&q=beach&tag=beach;"+
+		"private=1,false,/search?album=private&q=beach&tag=beach;",
+		html.UnescapeString(w.Body.String()))
+}
+
+func TestSearchFolders(t *testing.T) {
+	// given
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetLibrary("family", map[string]images.ImageFile{
+		"a.jpg":                  images.NewImageFile("a.jpg", "/family/a.jpg"),
+		"2021/Lisbon trip/b.jpg": images.NewImageFile("b.jpg", "/family/2021/Lisbon trip/b.jpg"),
+	}, false)
+	all := func(string) bool { return true }
+
+	// when
+	folder := fileHolder.Search(search.Query{Text: "lisbon"}, all)
+	name := fileHolder.Search(search.Query{Text: "b"}, all)
+
+	// then
+	assert.Equal(t, []string{"family:2021/Lisbon trip/b.jpg"}, folder.IDs, "photos should be found by the folders they are in")
+	assert.Equal(t, []string{"family:2021/Lisbon trip/b.jpg"}, name.IDs)
+}
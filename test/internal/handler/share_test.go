@@ -0,0 +1,160 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fotodeck/internal/handler"
+	"fotodeck/internal/share"
+	"fotodeck/internal/util"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var shareSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func newShareHandler(fileHolder *handler.FileHolder, auth *handler.Auth) handler.ShareHandler {
+	return handler.ShareHandler{
+		FileHolder:    fileHolder,
+		Settings:      &handler.SiteSettings{},
+		Auth:          auth,
+		Secret:        shareSecret,
+		DefaultExpiry: time.Hour,
+	}
+}
+
+func shareImageRequest(token string, id string) *http.Request {
+	req := httptest.NewRequest("GET", "http://mock/share/"+token+"/img/"+id, nil)
+	req.SetPathValue("token", token)
+	req.SetPathValue("id", id)
+	return req
+}
+
+func TestShareCreate(t *testing.T) {
+	files, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetLibrary("family", files, false)
+	auth := newAuth(t, false)
+	sh := newShareHandler(&fileHolder, auth)
+
+	for body, status := range map[string]int{
+		`{"album": "family", "expiresIn": 60}`:            201,
+		`{"photo": "family:fire.jpg"}`:                    201,
+		`{"album": "missing"}`:                            404,
+		`{"photo": "family:missing.jpg"}`:                 404,
+		`{"album": "family", "photo": "family:fire.jpg"}`: 400,
+		`not json`: 400,
+	} {
+		req := httptest.NewRequest("POST", "http://mock/api/share", strings.NewReader(body))
+		req.SetBasicAuth("bob", "secret")
+		w := httptest.NewRecorder()
+
+		// when
+		auth.Middleware(http.HandlerFunc(sh.Create)).ServeHTTP(w, req)
+
+		// then
+		assert.Equal(t, status, w.Code, body)
+		if status == 201 {
+			var resp handler.ShareResponse
+			assert.Nil(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Equal(t, "/share/"+resp.Token, resp.Url)
+			_, err := share.Verify(shareSecret, resp.Token, time.Now())
+			assert.Nil(t, err)
+		}
+	}
+}
+
+func TestShareCreateRequiresLogin(t *testing.T) {
+	// given
+	fileHolder := handler.FileHolder{}
+	auth := newAuth(t, false)
+	sh := newShareHandler(&fileHolder, auth)
+	req := httptest.NewRequest("POST", "http://mock/api/share", strings.NewReader(`{"album": "private"}`))
+	w := httptest.NewRecorder()
+
+	// when
+	auth.Middleware(http.HandlerFunc(sh.Create)).ServeHTTP(w, req)
+
+	// then
+	assert.Equal(t, 401, w.Code)
+}
+
+func TestShareCreateRestrictedAlbum(t *testing.T) {
+	files, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetLibrary("private", files, false)
+	auth := newAuth(t, false)
+	sh := newShareHandler(&fileHolder, auth)
+	req := httptest.NewRequest("POST", "http://mock/api/share", strings.NewReader(`{"album": "private"}`))
+	req.SetBasicAuth("bob", "secret")
+	w := httptest.NewRecorder()
+
+	// when
+	auth.Middleware(http.HandlerFunc(sh.Create)).ServeHTTP(w, req)
+
+	// then
+	assert.Equal(t, 404, w.Code, "Users should only share albums they can view")
+}
+
+func TestShareImages(t *testing.T) {
+	files, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetLibrary("family", files, false)
+	fileHolder.SetLibrary("work", files, false)
+	sh := newShareHandler(&fileHolder, nil)
+	album := share.Sign(shareSecret, util.Must(share.New(share.ScopeAlbum, "family", time.Hour)))
+	photo := share.Sign(shareSecret, util.Must(share.New(share.ScopePhoto, "family:fire.jpg", time.Hour)))
+	expired := share.Sign(shareSecret, share.Token{Scope: share.ScopeAlbum, ID: "family", Expires: time.Now().Add(-time.Minute).Unix()})
+
+	for _, tc := range []struct {
+		token  string
+		id     string
+		status int
+	}{
+		{album, "family:fire.jpg", 200},
+		{album, "family:ambience.jpg", 200},
+		{album, "work:fire.jpg", 404},
+		{photo, "family:fire.jpg", 200},
+		{photo, "family:ambience.jpg", 404},
+		{expired, "family:fire.jpg", 410},
+		{"garbage", "family:fire.jpg", 404},
+	} {
+		w := httptest.NewRecorder()
+
+		// when
+		sh.Images(w, shareImageRequest(tc.token, tc.id))
+
+		// then
+		assert.Equal(t, tc.status, w.Code, tc.id)
+	}
+}
+
+func TestShareCreateAlbumOfHomeLibrary(t *testing.T) {
+	files, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetEntries(files, false)
+	sh := newShareHandler(&fileHolder, nil)
+	w := httptest.NewRecorder()
+
+	// when
+	sh.Create(w, httptest.NewRequest("POST", "http://mock/api/share", strings.NewReader(`{"album": "workdir"}`)))
+
+	// then
+	assert.Equal(t, 404, w.Code)
+	assert.Contains(t, w.Body.String(), "only [[libraries]] and folders with an album.toml are albums", "The error should explain why the gallery can't be shared")
+}
@@ -0,0 +1,126 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fotodeck/internal/handler"
+	"fotodeck/internal/images"
+	"fotodeck/web"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func slideshowSettings() *handler.SiteSettings {
+	settings := &handler.SiteSettings{Title: "Home"}
+	settings.SetSlideshow(handler.SlideshowSettings{Interval: 10, Transition: "fade", Shuffle: true})
+	return settings
+}
+
+func photoIDs(photos []handler.Photo) []string {
+	ids := make([]string, 0, len(photos))
+	for _, photo := range photos {
+		ids = append(ids, photo.ID)
+	}
+	return ids
+}
+
+func TestSlideshowApi(t *testing.T) {
+	// given
+	api := handler.ApiHandler{FileHolder: searchFileHolder(), Settings: slideshowSettings(), Auth: newAuth(t, false)}
+
+	// when
+	anonymous := getJSON[handler.SlideshowResponse](t, api.Slideshow, api.Auth, "/api/slideshow", "")
+	alice := getJSON[handler.SlideshowResponse](t, api.Slideshow, api.Auth, "/api/slideshow", "alice")
+	hidden := getJSON[handler.SlideshowResponse](t, api.Slideshow, api.Auth, "/api/slideshow?album=archive&interval=60&transition=none&shuffle=false", "")
+
+	// then
+	assert.Equal(t, handler.SlideshowSettings{Interval: 10, Transition: "fade", Shuffle: true}, anonymous.Settings)
+	assert.Equal(t, []string{"family:a.jpg", "family:b.jpg"}, photoIDs(anonymous.Photos))
+	assert.Equal(t, "/img/family:a.jpg", anonymous.Photos[0].URL)
+	assert.Equal(t, []string{"family:a.jpg", "family:b.jpg", "private:c.jpg"}, photoIDs(alice.Photos))
+
+	assert.Equal(t, handler.SlideshowSettings{Interval: 60, Transition: "none", Album: "archive"}, hidden.Settings)
+	assert.Equal(t, []string{"archive:d.jpg"}, photoIDs(hidden.Photos), "hidden albums should be shown when asked for")
+}
+
+func TestSlideshowApiNewPhotos(t *testing.T) {
+	// given
+	fileHolder := searchFileHolder()
+	api := handler.ApiHandler{FileHolder: fileHolder, Settings: slideshowSettings()}
+	etag := serveAs(api.Slideshow, nil, "/api/slideshow?album=family", "").Header().Get("ETag")
+
+	// when
+	req := httptest.NewRequest("GET", "http://mock/api/slideshow?album=family", nil)
+	req.Header.Set("If-None-Match", etag)
+	unchanged := httptest.NewRecorder()
+	api.Slideshow(unchanged, req)
+
+	fileHolder.SetLibrary("family", map[string]images.ImageFile{
+		"a.jpg": images.NewImageFile("a.jpg", "/family/a.jpg"),
+		"e.jpg": images.NewImageFile("e.jpg", "/family/e.jpg"),
+	}, false)
+	changed := httptest.NewRecorder()
+	api.Slideshow(changed, req)
+
+	// then
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, http.StatusNotModified, unchanged.Code)
+	assert.Equal(t, http.StatusOK, changed.Code)
+	var resp handler.SlideshowResponse
+	assert.Nil(t, json.NewDecoder(changed.Body).Decode(&resp))
+	assert.Equal(t, []string{"family:a.jpg", "family:e.jpg"}, photoIDs(resp.Photos))
+}
+
+func TestSlideshowApiAlbumAccess(t *testing.T) {
+	// given
+	api := handler.ApiHandler{FileHolder: searchFileHolder(), Settings: slideshowSettings(), Auth: newAuth(t, false)}
+
+	// when
+	missing := serveAs(api.Slideshow, api.Auth, "/api/slideshow?album=missing", "alice")
+	anonymous := serveAs(api.Slideshow, api.Auth, "/api/slideshow?album=private", "")
+	bob := serveAs(api.Slideshow, api.Auth, "/api/slideshow?album=private", "bob")
+	alice := serveAs(api.Slideshow, api.Auth, "/api/slideshow?album=private", "alice")
+
+	// then
+	assert.Equal(t, http.StatusNotFound, missing.Code)
+	assert.Equal(t, http.StatusUnauthorized, anonymous.Code)
+	assert.Equal(t, http.StatusNotFound, bob.Code, "restricted albums should not be revealed to other users")
+	assert.Equal(t, http.StatusOK, alice.Code)
+}
+
+func TestSlideshowApiInvalidParams(t *testing.T) {
+	// given
+	api := handler.ApiHandler{FileHolder: searchFileHolder(), Settings: slideshowSettings()}
+
+	// when
+	codes := statusCodes(api.Slideshow, "/api/slideshow", "?interval=0", "?interval=x", "?transition=spin", "?shuffle=maybe")
+
+	// then
+	for query, code := range codes {
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
+
+func TestRenderEmbeddedSlideshow(t *testing.T) {
+	// given
+	templates, err := handler.NewTemplates(web.Files, false)
+	assert.Nil(t, err)
+	rh := handler.RootHandler{
+		FileHolder: searchFileHolder(),
+		Settings:   slideshowSettings(),
+		Templates:  templates,
+	}
+	w := httptest.NewRecorder()
+
+	// when
+	rh.Slideshow(w, httptest.NewRequest("GET", "http://mock/slideshow?album=family&interval=30", nil))
+
+	// then
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `data-photos="/api/slideshow?album=family&amp;interval=30"`)
+	assert.Contains(t, w.Body.String(), `data-interval="30"`)
+	assert.Contains(t, w.Body.String(), `class="transition-fade"`)
+	assert.Contains(t, w.Body.String(), "<title>family - Home</title>")
+}
@@ -0,0 +1,95 @@
+package handler_test
+
+import (
+	"fotodeck/internal/handler"
+	"fotodeck/web"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderEmbeddedTemplates(t *testing.T) {
+	files, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	templates, err := handler.NewTemplates(web.Files, false)
+	assert.Nil(t, err)
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetEntries(files, false)
+	rh := handler.RootHandler{
+		FileHolder: &fileHolder,
+		Settings:   &handler.SiteSettings{Title: "Embedded", Sort: "name"},
+		Templates:  templates,
+	}
+	w := httptest.NewRecorder()
+
+	// when
+	rh.Index(w, httptest.NewRequest("GET", "http://mock/", nil))
+
+	// then
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "Embedded")
+	assert.Contains(t, w.Body.String(), "/img/preview/ambience.jpg")
+}
+
+func TestTemplatesParsedOnce(t *testing.T) {
+	// given
+	fsys := fstest.MapFS{"template/page.html": {Data: []byte("first {{.}}")}}
+	templates, err := handler.NewTemplates(fsys, false)
+	assert.Nil(t, err)
+
+	// when
+	fsys["template/page.html"] = &fstest.MapFile{Data: []byte("second {{.}}")}
+	w := httptest.NewRecorder()
+	templates.Render(w, http.StatusTeapot, "page.html", "page")
+
+	// then
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Equal(t, "first page", w.Body.String())
+}
+
+func TestTemplatesDevModeReloads(t *testing.T) {
+	// given
+	fsys := fstest.MapFS{"template/page.html": {Data: []byte("first {{.}}")}}
+	templates, err := handler.NewTemplates(fsys, true)
+	assert.Nil(t, err)
+
+	// when
+	fsys["template/page.html"] = &fstest.MapFile{Data: []byte("second {{.}}")}
+	w := httptest.NewRecorder()
+	templates.Render(w, http.StatusOK, "page.html", "page")
+
+	// then
+	assert.Equal(t, "second page", w.Body.String())
+}
+
+func TestTemplatesFailingTemplate(t *testing.T) {
+	// given
+	fsys := fstest.MapFS{"template/page.html": {Data: []byte("{{.Missing}}")}}
+	templates, err := handler.NewTemplates(fsys, false)
+	assert.Nil(t, err)
+	w := httptest.NewRecorder()
+
+	// when
+	templates.Render(w, http.StatusOK, "page.html", "no fields")
+
+	// then
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestTemplatesInvalid(t *testing.T) {
+	// given
+	fsys := fstest.MapFS{"template/page.html": {Data: []byte("{{if}}")}}
+
+	// when
+	_, err := handler.NewTemplates(fsys, false)
+
+	// then
+	assert.Error(t, err)
+}
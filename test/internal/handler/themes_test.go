@@ -0,0 +1,91 @@
+package handler_test
+
+import (
+	"fotodeck/internal/handler"
+	"fotodeck/web"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThemeFSOverridesFiles(t *testing.T) {
+	// given
+	theme := fstest.MapFS{
+		"static/index.css": {Data: []byte("themed")},
+		"static/logo.png":  {Data: []byte("logo")},
+	}
+	themed := handler.ThemeFS(theme, web.Files)
+
+	// when
+	css, cssErr := fs.ReadFile(themed, "static/index.css")
+	js, jsErr := fs.ReadFile(themed, "static/index.js")
+	logo, logoErr := fs.ReadFile(themed, "static/logo.png")
+
+	// then
+	assert.Nil(t, cssErr)
+	assert.Equal(t, "themed", string(css))
+	assert.Nil(t, jsErr)
+	assert.NotEmpty(t, js, "files missing from the theme should fall back to the base")
+	assert.Nil(t, logoErr)
+	assert.Equal(t, "logo", string(logo))
+}
+
+func TestThemeFSMissingFile(t *testing.T) {
+	// given
+	themed := handler.ThemeFS(fstest.MapFS{}, web.Files)
+
+	// when
+	_, err := themed.Open("static/missing.css")
+
+	// then
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestThemeFSListsBothDirectories(t *testing.T) {
+	// given
+	theme := fstest.MapFS{"template/partial.html": {Data: []byte(`{{define "partial"}}{{end}}`)}}
+	themed := handler.ThemeFS(theme, web.Files)
+
+	// when
+	matches, err := fs.Glob(themed, "template/*.html")
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"template/index.html", "template/login.html", "template/map.html", "template/partial.html", "template/slideshow.html", "template/timeline.html"}, matches)
+}
+
+func TestThemeTemplateOverride(t *testing.T) {
+	// given
+	theme := fstest.MapFS{
+		"template/login.html":  {Data: []byte(`{{template "header" .}} themed login`)},
+		"template/header.html": {Data: []byte(`{{define "header"}}<header>{{.Next}}</header>{{end}}`)},
+	}
+	templates, err := handler.NewTemplates(handler.ThemeFS(theme, web.Files), false)
+	assert.Nil(t, err)
+	w := httptest.NewRecorder()
+
+	// when
+	templates.Render(w, http.StatusOK, "login.html", handler.LoginTemplate{Next: "/albums/a"})
+
+	// then
+	assert.Equal(t, "<header>/albums/a</header> themed login", w.Body.String())
+}
+
+func TestThemeFallsBackToEmbeddedTemplate(t *testing.T) {
+	// given
+	theme := fstest.MapFS{"template/login.html": {Data: []byte("themed login")}}
+	templates, err := handler.NewTemplates(handler.ThemeFS(theme, web.Files), false)
+	assert.Nil(t, err)
+	w := httptest.NewRecorder()
+
+	// when
+	templates.Render(w, http.StatusOK, "index.html", handler.IndexTemplate{Title: "Fallback"})
+
+	// then
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<h1>Fallback</h1>")
+}
@@ -0,0 +1,137 @@
+package handler_test
+
+import (
+	"fotodeck/internal/handler"
+	"fotodeck/internal/images"
+	"fotodeck/web"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+// timelineFileHolder has photos from two days in April 2021, one in December 2012 and one in a hidden album
+func timelineFileHolder() *handler.FileHolder {
+	taken := func(name string, date string) images.ImageFile {
+		t, _ := time.Parse(time.DateTime, date)
+		return images.NewImageFile(name, "/"+name).WithMetadata(images.Metadata{Taken: t})
+	}
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetLibrary("family", map[string]images.ImageFile{
+		"a.jpg": taken("a.jpg", "2021-04-01 08:43:14"),
+		"b.jpg": taken("b.jpg", "2021-04-01 18:00:00"),
+		"c.jpg": taken("c.jpg", "2021-04-03 12:00:00"),
+		"d.jpg": taken("d.jpg", "2012-12-15 10:00:00"),
+	}, false)
+	fileHolder.SetLibrary("archive", map[string]images.ImageFile{
+		"e.jpg": taken("e.jpg", "2019-06-01 10:00:00"),
+	}, false)
+	fileHolder.SetAlbum("archive", images.Album{Hidden: true})
+	return &fileHolder
+}
+
+func TestTimelineApi(t *testing.T) {
+	// given
+	api := handler.ApiHandler{FileHolder: timelineFileHolder()}
+
+	// when
+	months := getJSON[[]handler.TimelineBucket](t, api.Timeline, nil, "/api/timeline", "")
+	years := getJSON[[]handler.TimelineBucket](t, api.Timeline, nil, "/api/timeline?group=year", "")
+	days := getJSON[[]handler.TimelineBucket](t, api.Timeline, nil, "/api/timeline?group=day&year=2021", "")
+
+	// then
+	assert.Equal(t, []handler.TimelineBucket{
+		{Key: "2021-04", Label: "April 2021", Year: 2021, Month: 4, Count: 3, URL: "/timeline?year=2021&month=4"},
+		{Key: "2012-12", Label: "December 2012", Year: 2012, Month: 12, Count: 1, URL: "/timeline?year=2012&month=12"},
+	}, months, "hidden albums should be left out")
+	assert.Equal(t, []handler.TimelineBucket{
+		{Key: "2021", Label: "2021", Year: 2021, Count: 3, URL: "/timeline?year=2021"},
+		{Key: "2012", Label: "2012", Year: 2012, Count: 1, URL: "/timeline?year=2012"},
+	}, years)
+	assert.Equal(t, []handler.TimelineBucket{
+		{Key: "2021-04-03", Label: "3 April 2021", Year: 2021, Month: 4, Day: 3, Count: 1, URL: "/timeline?year=2021&month=4#2021-04-03"},
+		{Key: "2021-04-01", Label: "1 April 2021", Year: 2021, Month: 4, Day: 1, Count: 2, URL: "/timeline?year=2021&month=4#2021-04-01"},
+	}, days)
+}
+
+func TestTimelineApiRestricted(t *testing.T) {
+	// given
+	api := handler.ApiHandler{FileHolder: searchFileHolder(), Auth: newAuth(t, false)}
+	count := func(buckets []handler.TimelineBucket) int {
+		return lo.SumBy(buckets, func(bucket handler.TimelineBucket) int { return bucket.Count })
+	}
+
+	// when
+	photos := visiblePhotos(t, api.Timeline, api.Auth, "/api/timeline?group=year", count, "", "alice")
+
+	// then
+	assert.Equal(t, map[string]int{"": 2, "alice": 3}, photos)
+}
+
+func TestTimelineApiInvalidParams(t *testing.T) {
+	// given
+	api := handler.ApiHandler{FileHolder: timelineFileHolder()}
+
+	// when
+	codes := statusCodes(api.Timeline, "/api/timeline", "?group=week", "?year=x", "?year=-1")
+
+	// then
+	for query, code := range codes {
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
+
+func TestTimelinePage(t *testing.T) {
+	// given
+	fsys := fstest.MapFS{"template/timeline.html": {
+		Data: []byte(`{{.Year}}/{{.Month}}|{{range .Years}}{{.Key}}={{.Count}},{{end}}|{{range .Months}}{{.Key}}={{.Count}},{{end}}|` +
+			`{{range .Days}}{{.Key}}:{{range .Photos}}{{.ID}},{{end}};{{end}}`),
+	}}
+	templates, err := handler.NewTemplates(fsys, false)
+	assert.Nil(t, err)
+	rh := handler.RootHandler{
+		FileHolder: timelineFileHolder(),
+		Settings:   &handler.SiteSettings{},
+		Templates:  templates,
+	}
+	tests := map[string]string{
+		"":                   "2021/0|2021=3,2012=1,|2021-04=3,|2021-04-03:family:c.jpg,;2021-04-01:family:b.jpg,family:a.jpg,;",
+		"?year=2012":         "2012/0|2021=3,2012=1,|2012-12=1,|2012-12-15:family:d.jpg,;",
+		"?year=2021&month=5": "2021/5|2021=3,2012=1,|2021-04=3,|",
+		"?year=2019":         "2019/0|2021=3,2012=1,||",
+	}
+	for query, expected := range tests {
+		w := httptest.NewRecorder()
+
+		// when
+		rh.Timeline(w, httptest.NewRequest("GET", "http://mock/timeline"+query, nil))
+
+		// then
+		assert.Equal(t, expected, w.Body.String(), query)
+	}
+}
+
+func TestRenderEmbeddedTimeline(t *testing.T) {
+	// given
+	templates, err := handler.NewTemplates(web.Files, false)
+	assert.Nil(t, err)
+	rh := handler.RootHandler{
+		FileHolder: timelineFileHolder(),
+		Settings:   &handler.SiteSettings{Title: "Embedded"},
+		Templates:  templates,
+	}
+	w := httptest.NewRecorder()
+
+	// when
+	rh.Timeline(w, httptest.NewRequest("GET", "http://mock/timeline", nil))
+
+	// then
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `id="2021-04-01"`)
+	assert.Contains(t, w.Body.String(), "Thursday, 1 April 2021")
+	assert.Contains(t, w.Body.String(), "/img/preview/family:a.jpg")
+}
@@ -0,0 +1,124 @@
+package handler_test
+
+import (
+	"fotodeck/internal/handler"
+	"fotodeck/internal/images"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const secretContents = "TOP SECRET, NOT A PHOTO"
+
+// newImageServer routes image requests the same way as the fotodeck server
+func newImageServer(files *handler.FileHolder) *httptest.Server {
+	imageHandler := handler.ImageHandler{FileHolder: files}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/img/preview/{id}", imageHandler.Previews)
+	mux.HandleFunc("/img/{id}", imageHandler.Images)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestImageHandlerTraversal(t *testing.T) {
+	files, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	outside := filepath.Join(homePath, "..", "outside.jpg")
+	assert.Nil(t, os.WriteFile(outside, []byte(secretContents), os.FileMode(0644)))
+	defer os.Remove(outside)
+	absolute := filepath.Join(t.TempDir(), "secret.jpg")
+	assert.Nil(t, os.WriteFile(absolute, []byte(secretContents), os.FileMode(0644)))
+
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetLibrary("family", files, false)
+	server := newImageServer(&fileHolder)
+	defer server.Close()
+
+	for _, path := range []string{
+		"/img/../outside.jpg",
+		"/img/..%2foutside.jpg",
+		"/img/preview/..%2foutside.jpg",
+		"/img/%2e%2e%2foutside.jpg",
+		"/img/%2e%2e/outside.jpg",
+		"/img/..%5coutside.jpg",
+		"/img/family:..%2foutside.jpg",
+		"/img/family:..%2f..%2fhandler%2foutside.jpg",
+		"/img/family%3a..%2foutside.jpg",
+		"/img/" + filepath.ToSlash(absolute),
+		"/img/%2f" + filepath.ToSlash(absolute)[1:],
+		"/img/family:fire.jpg%00",
+		"/img/fire.jpg",
+	} {
+		// when
+		resp, err := server.Client().Get(server.URL + path)
+
+		// then
+		assert.Nil(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.NotEqual(t, 200, resp.StatusCode, path)
+		assert.NotContains(t, string(body), secretContents, path)
+	}
+}
+
+func TestImageHandlerServesLibraryPhoto(t *testing.T) {
+	files, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetLibrary("family", files, false)
+	server := newImageServer(&fileHolder)
+	defer server.Close()
+
+	// when
+	resp, err := server.Client().Get(server.URL + "/img/family:fire.jpg")
+
+	// then
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 200, resp.StatusCode, "Sanity check that the routes serve known IDs")
+}
+
+func TestImageHandlerServesPhotoInSubfolder(t *testing.T) {
+	files, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	nested := filepath.Join(homePath, "2021")
+	assert.Nil(t, os.Mkdir(nested, os.FileMode(0755)))
+	assert.Nil(t, os.WriteFile(filepath.Join(nested, "fire.jpg"), []byte("nested"), os.FileMode(0644)))
+	files["2021/fire.jpg"] = images.NewImageFile("fire.jpg", filepath.Join(nested, "fire.jpg"))
+	fileHolder := handler.FileHolder{}
+	fileHolder.SetLibrary("family", files, false)
+	server := newImageServer(&fileHolder)
+	defer server.Close()
+	photo := handler.Photo{ID: "family:2021/fire.jpg"}
+	templates, err := handler.NewTemplates(fstest.MapFS{
+		"template/index.html": {Data: []byte(`/img/{{pathEscape .ID}}`)},
+	}, false)
+	assert.Nil(t, err)
+	w := httptest.NewRecorder()
+	templates.Render(w, http.StatusOK, "index.html", photo)
+
+	// when
+	resp, err := server.Client().Get(server.URL + w.Body.String())
+
+	// then
+	assert.Nil(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "/img/family:2021%2Ffire.jpg", w.Body.String())
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "nested", string(body), "the photo in the subfolder should be served, not the one at the root")
+}
@@ -0,0 +1,109 @@
+package images_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fotodeck/internal/images"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadAlbum(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	err := os.WriteFile(filepath.Join(homePath, images.AlbumFile), []byte(`
+title = 'Family'
+description = 'Holidays and birthdays'
+cover = 'fire.jpg'
+sort = 'name-desc'
+hidden = true
+`), os.FileMode(0644))
+	assert.Nil(t, err)
+
+	// when
+	album, ok, err := loader.LoadAlbum(homePath)
+	entries, loadErr := loader.LoadOriginals(homePath)
+
+	// then
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, images.Album{
+		Title:       "Family",
+		Description: "Holidays and birthdays",
+		Cover:       "fire.jpg",
+		Sort:        "name-desc",
+		Hidden:      true,
+	}, album)
+	assert.Nil(t, loadErr)
+	assert.NotContains(t, entries, images.AlbumFile, "the album file should not be loaded as a photo")
+}
+
+func TestLoadAlbumMissing(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// when
+	album, ok, err := loader.LoadAlbum(homePath)
+
+	// then
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, images.Album{}, album)
+}
+
+func TestLoadAlbumOfFolder(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	folder := filepath.Join(homePath, "2021", "trip")
+	assert.Nil(t, os.MkdirAll(folder, os.FileMode(0755)))
+	assert.Nil(t, os.WriteFile(filepath.Join(folder, images.AlbumFile), []byte("title = 'Trip'"), os.FileMode(0644)))
+
+	// when
+	album, ok, err := loader.LoadAlbum(folder)
+	_, rootOk, rootErr := loader.LoadAlbum(homePath)
+
+	// then
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, images.Album{Title: "Trip"}, album)
+	assert.Nil(t, rootErr)
+	assert.False(t, rootOk, "the album file of a folder should not describe its parent")
+}
+
+func TestAlbumNames(t *testing.T) {
+	assert.Equal(t, "family", images.AlbumName("family", ""))
+	assert.Equal(t, "family:2021/trip", images.AlbumName("family", "2021/trip"))
+	assert.Equal(t, ":2021/trip", images.AlbumName("", "2021/trip"))
+
+	library, folder := images.SplitAlbumName(":2021/trip")
+	assert.Equal(t, "", library)
+	assert.Equal(t, "2021/trip", folder)
+
+	assert.True(t, images.InAlbum("family:2021/trip/a.jpg", "family"))
+	assert.True(t, images.InAlbum("family:2021/trip/day1/a.jpg", "family:2021/trip"))
+	assert.False(t, images.InAlbum("family:2021/trips/a.jpg", "family:2021/trip"))
+	assert.False(t, images.InAlbum("work:2021/trip/a.jpg", "family:2021/trip"))
+	assert.True(t, images.InAlbum("2021/trip/a.jpg", ":2021/trip"))
+	assert.False(t, images.InAlbum("2021/trip/a.jpg", "2021"), "home folders should not be mistaken for a library")
+}
+
+func TestLoadAlbumInvalid(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	err := os.WriteFile(filepath.Join(homePath, images.AlbumFile), []byte("title = "), os.FileMode(0644))
+	assert.Nil(t, err)
+
+	// when
+	_, _, err = loader.LoadAlbum(homePath)
+
+	// then
+	assert.ErrorContains(t, err, images.AlbumFile)
+}
@@ -0,0 +1,81 @@
+package images_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// gpsSegment is a JPEG APP1 segment holding EXIF with only a GPS position
+func gpsSegment(latitude float64, longitude float64) []byte {
+	tiff := new(bytes.Buffer)
+	le := binary.LittleEndian
+	write := func(values ...any) {
+		for _, value := range values {
+			_ = binary.Write(tiff, le, value)
+		}
+	}
+	ref := func(value float64, positive string, negative string) uint32 {
+		if value < 0 {
+			return uint32(negative[0])
+		}
+		return uint32(positive[0])
+	}
+
+	// header, then IFD0 pointing at the GPS IFD at 26, whose rational values start at 80
+	write([]byte("II"), uint16(42), uint32(8))
+	write(uint16(1), uint16(0x8825), uint16(4), uint32(1), uint32(26), uint32(0))
+	write(uint16(4))
+	write(uint16(1), uint16(2), uint32(2), ref(latitude, "N", "S"))
+	write(uint16(2), uint16(5), uint32(3), uint32(80))
+	write(uint16(3), uint16(2), uint32(2), ref(longitude, "E", "W"))
+	write(uint16(4), uint16(5), uint32(3), uint32(104))
+	write(uint32(0))
+	for _, value := range []float64{math.Abs(latitude), math.Abs(longitude)} {
+		degrees := math.Floor(value)
+		minutes := math.Floor((value - degrees) * 60)
+		seconds := math.Round(((value-degrees)*60 - minutes) * 60 * 1000)
+		write(uint32(degrees), uint32(1), uint32(minutes), uint32(1), uint32(seconds), uint32(1000))
+	}
+
+	segment := new(bytes.Buffer)
+	segment.Write([]byte{0xFF, 0xE1})
+	_ = binary.Write(segment, binary.BigEndian, uint16(2+6+tiff.Len()))
+	segment.WriteString("Exif\x00\x00")
+	segment.Write(tiff.Bytes())
+	return segment.Bytes()
+}
+
+// writeGeotagged copies the test photo from with a GPS position inserted before its own EXIF
+func writeGeotagged(t *testing.T, from string, name string, latitude float64, longitude float64) {
+	original, err := os.ReadFile(filepath.Join(homePath, from))
+	assert.Nil(t, err)
+	photo := append(append(original[:2:2], gpsSegment(latitude, longitude)...), original[2:]...)
+	assert.Nil(t, os.WriteFile(filepath.Join(homePath, name), photo, os.FileMode(0644)))
+}
+
+func TestMetadataLocation(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	writeGeotagged(t, "fire.jpg", "lisbon.jpg", 38.7223, -9.1393)
+	writeGeotagged(t, "fire.jpg", "nowhere.jpg", 0, 0)
+
+	// when
+	lisbon := loadMetadata(t, loader, "lisbon.jpg")
+	nowhere := loadMetadata(t, loader, "nowhere.jpg")
+	fire := loadMetadata(t, loader, "fire.jpg")
+
+	// then
+	assert.NotNil(t, lisbon.Location)
+	assert.InDelta(t, 38.7223, lisbon.Location.Latitude, 0.0001)
+	assert.InDelta(t, -9.1393, lisbon.Location.Longitude, 0.0001)
+	assert.Nil(t, nowhere.Location, "0, 0 is written by cameras without a GPS fix")
+	assert.Nil(t, fire.Location)
+}
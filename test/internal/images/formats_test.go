@@ -0,0 +1,126 @@
+package images_test
+
+import (
+	"bytes"
+	"fotodeck/internal/images"
+	"fotodeck/internal/util"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const maliciousSvg = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE svg>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="10" height="10" onload="alert(1)">
+  <script>alert(2)</script>
+  <a xlink:href="javascript:alert(3)"><rect width="10" height="10" fill="red" onclick="alert(4)"/></a>
+  <foreignObject><div><script>alert(5)</script></div></foreignObject>
+  <circle cx="5" cy="5" r="2"/>
+</svg>`
+
+func writeAnimatedGif(t *testing.T, path string, frames int) {
+	anim := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		frame := image.NewPaletted(image.Rect(0, 0, 400, 300), palette.Plan9)
+		for x := 0; x < 400; x++ {
+			for y := 0; y < 300; y++ {
+				frame.Set(x, y, color.RGBA{uint8(i * 40), uint8(x), uint8(y), 255})
+			}
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	err = gif.EncodeAll(f, anim)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSanitiseSvg(t *testing.T) {
+	// GIVEN
+	var out bytes.Buffer
+
+	// WHEN
+	err := images.SanitiseSvg(strings.NewReader(maliciousSvg), &out)
+
+	// THEN
+	assert.Nil(t, err)
+	result := out.String()
+	assert.NotContains(t, result, "alert")
+	assert.NotContains(t, result, "script")
+	assert.NotContains(t, result, "foreignObject")
+	assert.NotContains(t, result, "DOCTYPE")
+	assert.Contains(t, result, `xmlns:xlink="http://www.w3.org/1999/xlink"`)
+	assert.Contains(t, result, `<circle cx="5" cy="5" r="2">`)
+	assert.Contains(t, result, `<rect width="10" height="10" fill="red">`)
+}
+
+func TestSanitiseSvgInvalid(t *testing.T) {
+	var out bytes.Buffer
+
+	err := images.SanitiseSvg(strings.NewReader("<svg><g></svg>"), &out)
+
+	assert.ErrorContains(t, err, "failed to parse svg")
+}
+
+func TestLoaderSkipsSvg(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// GIVEN
+	err := os.WriteFile(filepath.Join(homePath, "vector.svg"), []byte(maliciousSvg), os.FileMode(0644))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := util.Must(loader.LoadOriginals(homePath))
+
+	// WHEN
+	err = loader.OptimiseImages(&files)
+
+	// THEN
+	assert.Nil(t, err)
+	svg := files["vector.svg"]
+	assert.False(t, svg.IsOptimised(), "SVG should not be resized")
+	assert.Equal(t, svg.GetFullSize(), svg.GetPreview())
+	assert.Equal(t, 0, util.Must(util.CountFilesByExtension(homePath, optExt+".svg")), "No SVG derivatives should be created")
+}
+
+func TestLoaderResizesAnimatedGif(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// GIVEN
+	const numFrames = 3
+	writeAnimatedGif(t, filepath.Join(homePath, "anim.gif"), numFrames)
+	files := util.Must(loader.LoadOriginals(homePath))
+
+	// WHEN
+	err := loader.OptimiseImages(&files)
+
+	// THEN
+	assert.Nil(t, err)
+	anim := files["anim.gif"]
+	assert.True(t, anim.IsOptimised())
+
+	preview := util.Must(images.OpenGif(anim.GetPreview()))
+	assert.Equal(t, numFrames, len(preview.Image), "Preview should keep every frame")
+	assert.Equal(t, []int{10, 10, 10}, preview.Delay, "Frame delays should be preserved")
+	assert.Equal(t, maxSize, preview.Config.Width)
+	assert.Equal(t, 150, preview.Config.Height)
+	for _, frame := range preview.Image {
+		assert.Equal(t, image.Rect(0, 0, maxSize, 150), frame.Bounds())
+	}
+}
@@ -0,0 +1,127 @@
+package images_test
+
+import (
+	"fotodeck/internal/images"
+	"fotodeck/internal/util"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// GIVEN
+	err := util.CopyFile(filepath.Join(homePath, "fire.jpg"), filepath.Join(homePath, "fire-copy.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := util.Must(loader.LoadOriginals(homePath))
+
+	// WHEN
+	err = loader.HashImages(&files)
+
+	// THEN
+	assert.Nil(t, err)
+	for _, file := range files {
+		assert.Len(t, file.Hash(), 64, "Every file should be hashed")
+	}
+	groups := images.FindDuplicates(files)
+	assert.Len(t, groups, 1)
+	assert.Equal(t, []string{"fire-copy.jpg", "fire.jpg"}, groups[0].Files)
+
+	unique := images.UniqueKeys(files)
+	slices.Sort(unique)
+	assert.Equal(t, []string{"ambience.jpg", "fire-copy.jpg"}, unique)
+}
+
+func TestDuplicatesShareDerivatives(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// GIVEN
+	err := util.CopyFile(filepath.Join(homePath, "fire.jpg"), filepath.Join(homePath, "fire-copy.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := util.Must(loader.LoadOriginals(homePath))
+
+	// WHEN
+	err = loader.OptimiseImages(&files)
+
+	// THEN
+	assert.Nil(t, err)
+	assert.Equal(t, numJpgFiles, util.Must(util.CountFilesByExtension(homePath, optExt+".jpg")), "Duplicates should not get their own derivatives")
+	original := files["fire.jpg"]
+	duplicate := files["fire-copy.jpg"]
+	assert.True(t, duplicate.IsOptimised())
+	assert.Equal(t, original.GetPreview(), duplicate.GetPreview())
+	assert.Equal(t, original.GetFullSize(), duplicate.GetFullSize())
+
+	for _, v := range files {
+		assert.Nil(t, v.Cleanup(), "Cleanup of shared derivatives should not fail")
+	}
+}
+
+func TestDuplicatesInDifferentFolders(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// GIVEN
+	for _, dir := range []string{"2021", "backup"} {
+		assert.Nil(t, os.Mkdir(filepath.Join(homePath, dir), os.FileMode(0755)))
+		err := util.CopyFile(filepath.Join(homePath, "fire.jpg"), filepath.Join(homePath, dir, "IMG_0001.jpg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := util.Must(loader.LoadOriginals(homePath))
+
+	// WHEN
+	err := loader.OptimiseImages(&files)
+
+	// THEN
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"ambience.jpg", "fire.jpg", "2021/IMG_0001.jpg", "backup/IMG_0001.jpg"}, keys(files), "Same-named files in different folders should both be loaded")
+	nested := files["backup/IMG_0001.jpg"]
+	assert.Equal(t, "IMG_0001.jpg", nested.Name())
+	groups := images.FindDuplicates(files)
+	assert.Len(t, groups, 1)
+	assert.Equal(t, []string{"2021/IMG_0001.jpg", "backup/IMG_0001.jpg", "fire.jpg"}, groups[0].Files)
+
+	for _, v := range files {
+		assert.Nil(t, v.Cleanup())
+	}
+}
+
+func TestDuplicatesOfUnresizableOriginal(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// GIVEN
+	for _, name := range []string{"broken.jpg", "broken-copy.jpg"} {
+		err := os.WriteFile(filepath.Join(homePath, name), []byte("not a jpeg"), os.FileMode(0644))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := util.Must(loader.LoadOriginals(homePath))
+
+	// WHEN
+	err := loader.OptimiseImages(&files)
+
+	// THEN
+	assert.Nil(t, err)
+	for _, name := range []string{"broken.jpg", "broken-copy.jpg"} {
+		file := files[name]
+		assert.False(t, file.IsOptimised(), "Failed resizes should not leave a derivative path")
+		assert.Equal(t, filepath.Join(homePath, name), file.GetFullSize(), "Duplicates should be served from their own original")
+		assert.Nil(t, file.Cleanup())
+	}
+	assert.FileExists(t, filepath.Join(homePath, "broken.jpg"), "Cleanup should never remove an original")
+	assert.FileExists(t, filepath.Join(homePath, "broken-copy.jpg"), "Cleanup should never remove an original")
+}
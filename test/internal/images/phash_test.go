@@ -0,0 +1,71 @@
+package images_test
+
+import (
+	"fotodeck/internal/images"
+	"fotodeck/internal/util"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+)
+
+const similarityThreshold = 10
+
+func writeResizedCopy(t *testing.T, src string, dst string) {
+	img, err := imaging.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = imaging.Save(imaging.Resize(img, 320, 0, imaging.Lanczos), dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHammingDistance(t *testing.T) {
+	assert.Equal(t, 0, images.HammingDistance(0xff, 0xff))
+	assert.Equal(t, 8, images.HammingDistance(0xff, 0x00))
+	assert.Equal(t, 64, images.HammingDistance(0, ^uint64(0)))
+}
+
+func TestFindSimilar(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// GIVEN
+	writeResizedCopy(t, filepath.Join(homePath, "fire.jpg"), filepath.Join(homePath, "fire-small.jpg"))
+	files := util.Must(loader.LoadOriginals(homePath))
+
+	// WHEN
+	err := loader.OptimiseImages(&files)
+
+	// THEN
+	assert.Nil(t, err)
+	for _, file := range files {
+		_, ok := file.PerceptualHash()
+		assert.True(t, ok, "Every image should have a perceptual hash")
+	}
+	similar := images.FindSimilar(files, "fire.jpg", similarityThreshold)
+	assert.Len(t, similar, 1)
+	assert.Equal(t, "fire-small.jpg", similar[0].ID)
+	assert.Empty(t, images.FindSimilar(files, "ambience.jpg", similarityThreshold))
+	assert.Empty(t, images.FindSimilar(files, "missing.jpg", similarityThreshold))
+}
+
+func TestClusterSimilar(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// GIVEN
+	writeResizedCopy(t, filepath.Join(homePath, "fire.jpg"), filepath.Join(homePath, "fire-small.jpg"))
+	files := util.Must(loader.LoadOriginals(homePath))
+	err := loader.PerceptualHashImages(&files)
+	assert.Nil(t, err)
+
+	// WHEN
+	clusters := images.ClusterSimilar(files, similarityThreshold)
+
+	// THEN
+	assert.Equal(t, [][]string{{"fire-small.jpg", "fire.jpg"}}, clusters)
+}
@@ -0,0 +1,154 @@
+package images_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fotodeck/internal/images"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const xmpSidecar = `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+    xmp:Rating="3">
+   <dc:description>
+    <rdf:Alt>
+     <rdf:li xml:lang="x-default">Campfire at dusk</rdf:li>
+     <rdf:li xml:lang="de">Lagerfeuer</rdf:li>
+    </rdf:Alt>
+   </dc:description>
+   <dc:subject>
+    <rdf:Bag>
+     <rdf:li>fire</rdf:li>
+     <rdf:li>camping</rdf:li>
+    </rdf:Bag>
+   </dc:subject>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`
+
+func writeSidecar(t *testing.T, name string, content string) {
+	err := os.WriteFile(filepath.Join(homePath, name), []byte(content), os.FileMode(0644))
+	assert.Nil(t, err)
+}
+
+func loadMetadata(t *testing.T, loader images.Loader, name string) images.Metadata {
+	entries, err := loader.LoadOriginals(homePath)
+	assert.Nil(t, err)
+	entry, ok := entries[name]
+	assert.True(t, ok)
+	return entry.Metadata()
+}
+
+// loadAnnotations is the part of the metadata of a photo read from sidecars
+func loadAnnotations(t *testing.T, loader images.Loader, name string) images.Metadata {
+	metadata := loadMetadata(t, loader, name)
+	return images.Metadata{Caption: metadata.Caption, Tags: metadata.Tags, Rating: metadata.Rating}
+}
+
+func TestSidecarXMP(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	writeSidecar(t, "fire.jpg.xmp", xmpSidecar)
+
+	// when
+	metadata := loadAnnotations(t, loader, "fire.jpg")
+
+	// then
+	assert.Equal(t, images.Metadata{Caption: "Campfire at dusk", Tags: []string{"fire", "camping"}, Rating: 3}, metadata)
+	assert.Equal(t, images.Metadata{}, loadAnnotations(t, loader, "ambience.jpg"))
+}
+
+func TestSidecarXMPWithoutExtension(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	writeSidecar(t, "fire.xmp", `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description xmlns:xmp="http://ns.adobe.com/xap/1.0/"><xmp:Rating>-1</xmp:Rating></rdf:Description>
+<rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:subject><rdf:Bag><rdf:li>fire</rdf:li></rdf:Bag></dc:subject></rdf:Description>
+</rdf:RDF></x:xmpmeta>`)
+
+	// when
+	metadata := loadAnnotations(t, loader, "fire.jpg")
+
+	// then
+	assert.Equal(t, images.Metadata{Tags: []string{"fire"}}, metadata, "rejected photos should be unrated")
+}
+
+func TestSidecarTOMLOverridesXMP(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	writeSidecar(t, "fire.jpg.xmp", xmpSidecar)
+	writeSidecar(t, "fire.jpg.toml", `
+caption = 'Our first campfire'
+tags = ['fire', 'summer']
+rating = 5
+`)
+
+	// when
+	metadata := loadAnnotations(t, loader, "fire.jpg")
+
+	// then
+	assert.Equal(t, images.Metadata{Caption: "Our first campfire", Tags: []string{"fire", "camping", "summer"}, Rating: 5}, metadata)
+}
+
+func TestSidecarInvalid(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	writeSidecar(t, "fire.jpg.xmp", "<x:xmpmeta><unclosed>")
+	writeSidecar(t, "fire.jpg.toml", "rating = 9\ncaption = 'Still read'")
+	writeSidecar(t, "ambience.jpg.toml", "caption = ")
+
+	// when
+	fire := loadAnnotations(t, loader, "fire.jpg")
+	ambience := loadAnnotations(t, loader, "ambience.jpg")
+
+	// then
+	assert.Equal(t, images.Metadata{Caption: "Still read"}, fire)
+	assert.Equal(t, images.Metadata{}, ambience)
+}
+
+func TestMetadataFromExif(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// when
+	ambience := loadMetadata(t, loader, "ambience.jpg")
+	fire := loadMetadata(t, loader, "fire.jpg")
+
+	// then
+	assert.Equal(t, "Canon EOS 70D", ambience.Camera)
+	assert.Equal(t, time.Date(2021, 4, 1, 8, 43, 14, 0, time.Local), ambience.Taken)
+	assert.Equal(t, "", fire.Camera)
+	assert.Equal(t, 2012, fire.Taken.Year())
+}
+
+func TestMetadataWithoutExifUsesModTime(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// given
+	modified := time.Date(2020, 2, 3, 4, 5, 6, 0, time.UTC)
+	path := filepath.Join(homePath, "copy.png")
+	writeSidecar(t, "copy.png", "not really a png")
+	assert.Nil(t, os.Chtimes(path, modified, modified))
+
+	// when
+	metadata := loadMetadata(t, loader, "copy.png")
+
+	// then
+	assert.True(t, modified.Equal(metadata.Taken))
+}
@@ -0,0 +1,126 @@
+package images_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fotodeck/internal/util"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupSymlinks adds symlinks to workdir:
+//
+//	workdir/link.jpg      -> workdir/fire.jpg
+//	workdir/album         -> workdir/nested
+//	workdir/nested/loop   -> workdir
+//	workdir/outside.jpg   -> <outside>/secret.jpg
+//	workdir/outside-dir   -> <outside>
+//
+// and returns the absolute path of the outside directory
+func setupSymlinks(t *testing.T) string {
+	outside := t.TempDir()
+	err := os.WriteFile(filepath.Join(outside, "secret.jpg"), util.Must(os.ReadFile(filepath.Join(dataPath, "fire.jpg"))), os.FileMode(0644))
+	assert.Nil(t, err)
+
+	home := util.Must(filepath.Abs(homePath))
+	nested := filepath.Join(home, "nested")
+	assert.Nil(t, os.Mkdir(nested, os.FileMode(0755)))
+	assert.Nil(t, os.Symlink(filepath.Join(home, "fire.jpg"), filepath.Join(home, "link.jpg")))
+	assert.Nil(t, os.Symlink(nested, filepath.Join(home, "album")))
+	assert.Nil(t, os.Symlink(home, filepath.Join(nested, "loop")))
+	assert.Nil(t, os.Symlink(filepath.Join(outside, "secret.jpg"), filepath.Join(home, "outside.jpg")))
+	assert.Nil(t, os.Symlink(outside, filepath.Join(home, "outside-dir")))
+	return outside
+}
+
+func TestLoaderSkipsSymlinks(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// GIVEN
+	setupSymlinks(t)
+
+	// WHEN
+	files := util.Must(loader.LoadOriginals(homePath))
+
+	// THEN
+	assert.ElementsMatch(t, []string{"ambience.jpg", "fire.jpg"}, keys(files), "Symlinks should be skipped by default")
+}
+
+func TestLoaderFollowsSymlinksWithinRoot(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// GIVEN
+	setupSymlinks(t)
+	loader.FollowSymlinks = true
+
+	// WHEN
+	files := util.Must(loader.LoadOriginals(homePath))
+
+	// THEN
+	assert.ElementsMatch(t, []string{"ambience.jpg", "fire.jpg", "link.jpg"}, keys(files), "Symlinks outside the home path should be skipped")
+	link := files["link.jpg"]
+	assert.Equal(t, filepath.Join(homePath, "link.jpg"), link.GetFullSize())
+}
+
+func TestLoaderFollowsSymlinksToAllowedRoots(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// GIVEN
+	outside := setupSymlinks(t)
+	loader.FollowSymlinks = true
+	loader.AllowedRoots = []string{outside}
+
+	// WHEN
+	files := util.Must(loader.LoadOriginals(homePath))
+
+	// THEN
+	assert.ElementsMatch(t, []string{"ambience.jpg", "fire.jpg", "link.jpg", "outside.jpg", "outside-dir/secret.jpg"}, keys(files))
+	secret := files["outside-dir/secret.jpg"]
+	assert.Equal(t, filepath.Join(homePath, "outside-dir", "secret.jpg"), secret.GetFullSize())
+}
+
+func TestLoaderSymlinkedHomePath(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// GIVEN
+	link := filepath.Join(t.TempDir(), "photos")
+	assert.Nil(t, os.Symlink(util.Must(filepath.Abs(homePath)), link))
+
+	// WHEN
+	files := util.Must(loader.LoadOriginals(link))
+
+	// THEN
+	assert.Len(t, files, numJpgFiles, "A symlinked home path should be loaded even when not following symlinks")
+}
+
+func keys[V any](m map[string]V) []string {
+	k := make([]string, 0, len(m))
+	for key := range m {
+		k = append(k, key)
+	}
+	return k
+}
+
+func TestFoldersFollowSymlinkPolicy(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// GIVEN
+	outside := setupSymlinks(t)
+
+	// WHEN
+	skipped := util.Must(loader.Folders(homePath))
+	loader.FollowSymlinks = true
+	loader.AllowedRoots = []string{outside}
+	followed := util.Must(loader.Folders(homePath))
+
+	// THEN
+	assert.Equal(t, []string{homePath, filepath.Join(homePath, "nested")}, skipped, "Symlinked folders should be skipped by default")
+	assert.Equal(t, []string{homePath, filepath.Join(homePath, "album"), filepath.Join(homePath, "outside-dir")}, followed, "Each folder should be listed once")
+}
@@ -0,0 +1,93 @@
+package images_test
+
+import (
+	"fotodeck/internal/images"
+	"fotodeck/internal/util"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyImages(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// GIVEN
+	files := util.Must(loader.LoadOriginals(homePath))
+
+	// WHEN
+	missing := util.Must(loader.VerifyImages(files))
+	err := loader.OptimiseImages(&files)
+	assert.Nil(t, err)
+	healthy := util.Must(loader.VerifyImages(files))
+
+	fire := files["fire.jpg"]
+	err = os.Truncate(fire.GetPreview(), 10)
+	assert.Nil(t, err)
+	corrupt := util.Must(loader.VerifyImages(files))
+
+	// THEN
+	assert.Len(t, missing, numJpgFiles*2, "Every derivative should be missing before optimisation")
+	for _, report := range missing {
+		assert.Equal(t, images.DerivativeMissing, report.Status)
+	}
+	assert.Empty(t, healthy, "No problems should be found after optimisation")
+	assert.Len(t, corrupt, 1)
+	assert.Equal(t, images.DerivativeCorrupt, corrupt[0].Status)
+	assert.Equal(t, fire.GetPreview(), corrupt[0].Path)
+}
+
+func TestRepairImages(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// GIVEN
+	files := util.Must(loader.LoadOriginals(homePath))
+	err := loader.OptimiseImages(&files)
+	assert.Nil(t, err)
+	fire := files["fire.jpg"]
+	err = os.Truncate(fire.GetPreview(), 10)
+	assert.Nil(t, err)
+	// resize settings changed since the optimised images were created
+	loader.MaxOptimisedDimensions = images.Dimensions{Width: maxSize / 2, Height: maxSize / 2}
+
+	// WHEN
+	repaired := util.Must(loader.RepairImages(files))
+
+	// THEN
+	assert.Len(t, repaired, numJpgFiles+1)
+	for _, report := range repaired {
+		assert.True(t, report.Repaired, "Derivative should be repaired: %s", report.Path)
+		assert.NotEqual(t, images.DerivativeMissing, report.Status)
+	}
+	assert.Empty(t, util.Must(loader.VerifyImages(files)), "No problems should remain after repair")
+}
+
+func TestRepairImagesReplacesAtomically(t *testing.T) {
+	loader, teardown := setupTest(t)
+	defer teardown(t)
+
+	// GIVEN
+	files := util.Must(loader.LoadOriginals(homePath))
+	err := loader.OptimiseImages(&files)
+	assert.Nil(t, err)
+	fire := files["fire.jpg"]
+	stale := util.Must(os.ReadFile(fire.GetFullSize()))
+	// the original can no longer be resized, e.g. it is being rewritten
+	err = os.WriteFile(filepath.Join(homePath, "fire.jpg"), []byte("not a jpeg"), os.FileMode(0644))
+	assert.Nil(t, err)
+
+	// WHEN
+	repaired := util.Must(loader.RepairImages(files))
+
+	// THEN
+	assert.NotEmpty(t, repaired)
+	for _, report := range repaired {
+		assert.False(t, report.Repaired)
+	}
+	assert.Equal(t, stale, util.Must(os.ReadFile(fire.GetFullSize())), "A failed repair should keep the existing derivative")
+	leftovers := util.Must(filepath.Glob(filepath.Join(homePath, "*.tmp")))
+	assert.Empty(t, leftovers, "Temporary files should be removed")
+}
@@ -0,0 +1,100 @@
+package search_test
+
+import (
+	"fotodeck/internal/search"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testIndex() *search.Index {
+	return search.NewIndex([]search.Document{
+		{
+			ID: "family:IMG_0001.jpg", Name: "IMG_0001.jpg", Album: "family", AlbumTitle: "Family Holidays",
+			Caption: "Building sandcastles on the beach", Tags: []string{"beach", "kids"},
+			Camera: "Canon EOS 70D", Taken: time.Date(2021, 7, 1, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			ID: "family:IMG_0002.jpg", Name: "IMG_0002.jpg", Album: "family", AlbumTitle: "Family Holidays",
+			Caption: "Campfire", Tags: []string{"fire"},
+			Camera: "Canon EOS 70D", Taken: time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			ID: "work:2023/offsite/whiteboard.png", Name: "whiteboard.png", Folder: "2023/offsite", Album: "work",
+			Tags: []string{"beach"}, Camera: "Pixel 7", Taken: time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC),
+		},
+	})
+}
+
+func all(string) bool {
+	return true
+}
+
+func TestSearchText(t *testing.T) {
+	// given
+	index := testIndex()
+
+	// when
+	sand := index.Search(search.Query{Text: "sand"}, all)
+	words := index.Search(search.Query{Text: "Beach CANON"}, all)
+	album := index.Search(search.Query{Text: "holidays"}, all)
+	year := index.Search(search.Query{Text: "2021"}, all)
+	folder := index.Search(search.Query{Text: "offsite"}, all)
+	none := index.Search(search.Query{Text: "beach nothing"}, all)
+
+	// then
+	assert.Equal(t, []string{"family:IMG_0001.jpg"}, sand.IDs, "words should match by prefix")
+	assert.Equal(t, []string{"family:IMG_0001.jpg"}, words.IDs, "every word should match, ignoring case")
+	assert.Equal(t, []string{"family:IMG_0002.jpg", "family:IMG_0001.jpg"}, album.IDs, "results should be newest first")
+	assert.Equal(t, []string{"family:IMG_0001.jpg", "work:2023/offsite/whiteboard.png"}, year.IDs)
+	assert.Equal(t, []string{"work:2023/offsite/whiteboard.png"}, folder.IDs, "folders within the album should be searchable")
+	assert.Empty(t, none.IDs)
+}
+
+func TestSearchFilters(t *testing.T) {
+	// given
+	index := testIndex()
+
+	// when
+	result := index.Search(search.Query{Filters: map[string][]string{
+		search.FacetTag:  {"beach"},
+		search.FacetYear: {"2021"},
+	}}, all)
+	camera := index.Search(search.Query{Text: "beach", Filters: map[string][]string{
+		search.FacetCamera: {"pixel 7"},
+	}}, all)
+
+	// then
+	assert.Equal(t, []string{"family:IMG_0001.jpg", "work:2023/offsite/whiteboard.png"}, result.IDs)
+	assert.Equal(t, []string{"work:2023/offsite/whiteboard.png"}, camera.IDs)
+}
+
+func TestSearchFacets(t *testing.T) {
+	// given
+	index := testIndex()
+
+	// when
+	result := index.Search(search.Query{}, all)
+
+	// then
+	assert.Len(t, result.IDs, 3)
+	assert.Equal(t, []search.FacetCount{{Value: "2021", Count: 2}, {Value: "2022", Count: 1}}, result.Facets[search.FacetYear])
+	assert.Equal(t, []search.FacetCount{{Value: "Canon EOS 70D", Count: 2}, {Value: "Pixel 7", Count: 1}}, result.Facets[search.FacetCamera])
+	assert.Equal(t, []search.FacetCount{{Value: "beach", Count: 2}, {Value: "fire", Count: 1}, {Value: "kids", Count: 1}}, result.Facets[search.FacetTag])
+	assert.Equal(t, []search.FacetCount{{Value: "family", Count: 2}, {Value: "work", Count: 1}}, result.Facets[search.FacetAlbum])
+}
+
+func TestSearchAllowed(t *testing.T) {
+	// given
+	index := testIndex()
+
+	// when
+	result := index.Search(search.Query{Text: "beach"}, func(id string) bool {
+		return id != "work:2023/offsite/whiteboard.png"
+	})
+
+	// then
+	assert.Equal(t, []string{"family:IMG_0001.jpg"}, result.IDs)
+	assert.Equal(t, []search.FacetCount{{Value: "family", Count: 1}}, result.Facets[search.FacetAlbum], "facets should only count allowed results")
+}
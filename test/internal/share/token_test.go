@@ -0,0 +1,76 @@
+package share_test
+
+import (
+	"fotodeck/internal/share"
+	"fotodeck/internal/util"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var secret = []byte("0123456789abcdef0123456789abcdef")
+
+func TestTokenRoundTrip(t *testing.T) {
+	// given
+	token := util.Must(share.New(share.ScopeAlbum, "family", time.Hour))
+
+	// when
+	verified, err := share.Verify(secret, share.Sign(secret, token), time.Now())
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, token, verified)
+	assert.True(t, verified.Allows("family:beach.jpg"))
+	assert.False(t, verified.Allows("work:beach.jpg"))
+	assert.False(t, verified.Allows("beach.jpg"))
+}
+
+func TestTokenPhotoScope(t *testing.T) {
+	token := util.Must(share.New(share.ScopePhoto, "family:beach.jpg", time.Hour))
+
+	assert.True(t, token.Allows("family:beach.jpg"))
+	assert.False(t, token.Allows("family:other.jpg"))
+}
+
+func TestTokenExpired(t *testing.T) {
+	// given
+	signed := share.Sign(secret, util.Must(share.New(share.ScopeAlbum, "family", time.Hour)))
+
+	// when
+	_, err := share.Verify(secret, signed, time.Now().Add(2*time.Hour))
+
+	// then
+	assert.ErrorIs(t, err, share.ErrExpiredToken)
+}
+
+func TestTokenTampered(t *testing.T) {
+	// given
+	signed := share.Sign(secret, util.Must(share.New(share.ScopeAlbum, "family", time.Hour)))
+	payload, sig, _ := strings.Cut(signed, ".")
+	forged := share.Sign(secret, util.Must(share.New(share.ScopeAlbum, "work", time.Hour)))
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+
+	for name, token := range map[string]string{
+		"swapped payload": forgedPayload + "." + sig,
+		"other secret":    share.Sign([]byte("another secret, also 32 characters"), util.Must(share.New(share.ScopeAlbum, "family", time.Hour))),
+		"no signature":    payload,
+		"empty":           "",
+	} {
+		// when
+		_, err := share.Verify(secret, token, time.Now())
+
+		// then
+		assert.ErrorIs(t, err, share.ErrInvalidToken, name)
+	}
+}
+
+func TestNewTokenValidation(t *testing.T) {
+	_, err := share.New("library", "family", time.Hour)
+	assert.NotNil(t, err)
+	_, err = share.New(share.ScopePhoto, "", time.Hour)
+	assert.NotNil(t, err)
+	_, err = share.New(share.ScopePhoto, "a.jpg", -time.Hour)
+	assert.NotNil(t, err)
+}
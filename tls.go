@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader serves the certificate at certFile, reloading it when the certificate
+// or key file is modified, e.g. when it has been renewed
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modified time.Time
+}
+
+// newCertReloader loads the certificate, failing if it can't be used
+func newCertReloader(certFile string, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	modified, err := c.lastModified()
+	if err != nil {
+		return nil, err
+	}
+	err = c.load(modified)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate is used as tls.Config.GetCertificate
+func (c *certReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	modified, err := c.lastModified()
+	if err != nil {
+		slog.Error("failed to check TLS certificate, serving the current certificate", "error", err)
+		return c.cert, nil
+	}
+	if modified.After(c.modified) {
+		err = c.load(modified)
+		if err != nil {
+			// the files may be midway through being replaced, try again on the next handshake
+			slog.Error("failed to reload TLS certificate, serving the current certificate", "error", err)
+		}
+	}
+	return c.cert, nil
+}
+
+// load reads the certificate and key. Must be called with mu held, or before the reloader is shared
+func (c *certReloader) load(modified time.Time) error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate '%s' and key '%s': %w", c.certFile, c.keyFile, err)
+	}
+	c.cert = &cert
+	c.modified = modified
+	slog.Info("loaded TLS certificate", "cert", c.certFile)
+	return nil
+}
+
+// lastModified is the latest modification time of the certificate and key files
+func (c *certReloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{c.certFile, c.keyFile} {
+		stat, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if stat.ModTime().After(latest) {
+			latest = stat.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -6,24 +6,44 @@ function showModal() {
   let photoSrc = state.currentPhoto.src;
   photoSrc = photoSrc.replaceAll("/preview", "");
   document.querySelector("#full-image").src = photoSrc;
+  showCaption(state.currentPhoto.dataset);
   document.querySelector("#image-viewer").style.display = "block";
   refreshArrows();
 }
 
+function showCaption(photo) {
+  let caption = document.querySelector("#full-caption");
+  if (!caption) {
+    // themes may leave the caption out
+    return;
+  }
+  let rating = parseInt(photo.rating || "0", 10);
+  caption.querySelector(".rating").textContent = "\u2605".repeat(rating);
+  caption.querySelector(".caption").textContent = photo.caption || "";
+  caption.querySelector(".tags").textContent = photo.tags || "";
+}
+
 function hideModal() {
   document.querySelector("#image-viewer").style.display = "none";
 }
 
+// neighbour is the photo before or after the current one, across every group of photos on the page
+function neighbour(previous = false) {
+  let photos = Array.from(document.querySelectorAll(".images img"));
+  let index = photos.indexOf(state.currentPhoto) + (previous ? -1 : 1);
+  return photos[index];
+}
+
 function refreshArrows() {
   let nextButton = document.querySelector("#image-viewer .next");
-  if (state.currentPhoto.nextElementSibling) {
+  if (neighbour()) {
     nextButton.style.display = "block";
   } else {
     nextButton.style.display = "none";
   }
 
   let prevButton = document.querySelector("#image-viewer .prev");
-  if (state.currentPhoto.previousElementSibling) {
+  if (neighbour(true)) {
     prevButton.style.display = "block";
   } else {
     prevButton.style.display = "none";
@@ -31,14 +51,9 @@ function refreshArrows() {
 }
 
 function changeImage(previous = false) {
-  if (previous) {
-    if (state.currentPhoto.previousElementSibling) {
-      state.currentPhoto = state.currentPhoto.previousElementSibling;
-    }
-  } else {
-    if (state.currentPhoto.nextElementSibling) {
-      state.currentPhoto = state.currentPhoto.nextElementSibling;
-    }
+  let photo = neighbour(previous);
+  if (photo) {
+    state.currentPhoto = photo;
   }
 
   showModal();
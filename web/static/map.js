@@ -0,0 +1,292 @@
+// A small Web Mercator map without dependencies, so it also works offline against a local tile
+// server. Photo clusters are loaded from /api/geo for the visible area whenever the map moves.
+const TILE_SIZE = 256;
+// zoom level single photos are shown at when the map opens
+const PHOTO_ZOOM = 14;
+
+let map = document.querySelector("#map");
+let tileUrl = map.dataset.tiles;
+let maxZoom = parseInt(map.dataset.maxZoom || "19", 10);
+let tiles = map.querySelector(".tiles");
+let markers = map.querySelector(".markers");
+
+// center in pixels of the whole world at the zoom level
+let view = { zoom: 2, x: 0, y: 0 };
+let loadTimer = null;
+
+function worldSize(zoom) {
+  return TILE_SIZE * Math.pow(2, zoom);
+}
+
+function project(latitude, longitude, zoom) {
+  let size = worldSize(zoom);
+  let sin = Math.sin((Math.max(Math.min(latitude, 85.05), -85.05) * Math.PI) / 180);
+  return {
+    x: ((longitude + 180) / 360) * size,
+    y: (0.5 - Math.log((1 + sin) / (1 - sin)) / (4 * Math.PI)) * size,
+  };
+}
+
+function unproject(x, y, zoom) {
+  let size = worldSize(zoom);
+  let n = Math.PI - (2 * Math.PI * y) / size;
+  return {
+    latitude: (180 / Math.PI) * Math.atan(Math.sinh(n)),
+    longitude: (x / size) * 360 - 180,
+  };
+}
+
+function setView(latitude, longitude, zoom) {
+  view.zoom = Math.max(0, Math.min(zoom, maxZoom));
+  let center = project(latitude, longitude, view.zoom);
+  view.x = center.x;
+  view.y = center.y;
+  render();
+}
+
+function center() {
+  return unproject(view.x, view.y, view.zoom);
+}
+
+function topLeft() {
+  return { x: view.x - map.clientWidth / 2, y: view.y - map.clientHeight / 2 };
+}
+
+function render() {
+  let size = worldSize(view.zoom);
+  let count = Math.pow(2, view.zoom);
+  // keep the world from leaving the screen vertically
+  let halfHeight = map.clientHeight / 2;
+  view.y = size < map.clientHeight ? size / 2 : Math.max(halfHeight, Math.min(view.y, size - halfHeight));
+  // wrap horizontally, the world repeats
+  view.x = ((view.x % size) + size) % size;
+
+  let origin = topLeft();
+  let wanted = new Set();
+  for (let tx = Math.floor(origin.x / TILE_SIZE); tx * TILE_SIZE < origin.x + map.clientWidth; tx++) {
+    for (let ty = Math.max(0, Math.floor(origin.y / TILE_SIZE)); ty < count && ty * TILE_SIZE < origin.y + map.clientHeight; ty++) {
+      let key = view.zoom + "/" + tx + "/" + ty;
+      wanted.add(key);
+      let tile = tiles.querySelector(`[data-key="${key}"]`);
+      if (!tile) {
+        tile = document.createElement("img");
+        tile.dataset.key = key;
+        tile.alt = "";
+        tile.draggable = false;
+        tile.src = tileUrl
+          .replace("{z}", view.zoom)
+          .replace("{x}", ((tx % count) + count) % count)
+          .replace("{y}", ty);
+        tiles.appendChild(tile);
+      }
+      tile.style.left = tx * TILE_SIZE - origin.x + "px";
+      tile.style.top = ty * TILE_SIZE - origin.y + "px";
+    }
+  }
+  tiles.querySelectorAll("img").forEach((tile) => {
+    if (!wanted.has(tile.dataset.key)) {
+      tile.remove();
+    }
+  });
+
+  markers.querySelectorAll(".marker").forEach(placeMarker);
+  scheduleLoad();
+}
+
+// placeMarker positions a marker on the copy of the world closest to the center of the map
+function placeMarker(marker) {
+  let point = project(parseFloat(marker.dataset.latitude), parseFloat(marker.dataset.longitude), view.zoom);
+  let size = worldSize(view.zoom);
+  let x = point.x + Math.round((view.x - point.x) / size) * size;
+  let origin = topLeft();
+  marker.style.left = x - origin.x + "px";
+  marker.style.top = point.y - origin.y + "px";
+}
+
+function bbox() {
+  let origin = topLeft();
+  if (map.clientWidth >= worldSize(view.zoom)) {
+    let north = unproject(0, Math.max(origin.y, 0), view.zoom).latitude;
+    let south = unproject(0, Math.min(origin.y + map.clientHeight, worldSize(view.zoom)), view.zoom).latitude;
+    return [-180, south, 180, north];
+  }
+  let northWest = unproject(origin.x, Math.max(origin.y, 0), view.zoom);
+  let southEast = unproject(origin.x + map.clientWidth, Math.min(origin.y + map.clientHeight, worldSize(view.zoom)), view.zoom);
+  let wrap = (longitude) => ((((longitude + 180) % 360) + 360) % 360) - 180;
+  return [wrap(northWest.longitude), southEast.latitude, wrap(southEast.longitude), northWest.latitude];
+}
+
+// scheduleLoad loads the photos once the map stops moving
+function scheduleLoad() {
+  clearTimeout(loadTimer);
+  loadTimer = setTimeout(loadPhotos, 200);
+}
+
+async function fetchClusters(box, zoom) {
+  let params = new URLSearchParams({ bbox: box.map((v) => v.toFixed(6)).join(","), zoom: zoom });
+  let response = await fetch("/api/geo?" + params);
+  if (!response.ok) {
+    return [];
+  }
+  return (await response.json()).features;
+}
+
+async function loadPhotos() {
+  let zoom = view.zoom;
+  let features = await fetchClusters(bbox(), zoom);
+  if (zoom !== view.zoom) {
+    return;
+  }
+  markers.replaceChildren(...features.map(newMarker));
+  markers.querySelectorAll(".marker").forEach(placeMarker);
+  let position = center();
+  history.replaceState(null, "", `#${view.zoom}/${position.latitude.toFixed(5)}/${position.longitude.toFixed(5)}`);
+}
+
+function newMarker(feature) {
+  let [longitude, latitude] = feature.geometry.coordinates;
+  let { count, photo } = feature.properties;
+  let marker = document.createElement("button");
+  marker.className = "marker";
+  marker.dataset.latitude = latitude;
+  marker.dataset.longitude = longitude;
+  marker.title = photo.caption || photo.name;
+  let preview = document.createElement("img");
+  preview.src = photo.previewUrl;
+  preview.alt = photo.name;
+  preview.loading = "lazy";
+  marker.appendChild(preview);
+  if (count > 1) {
+    let badge = document.createElement("span");
+    badge.className = "count";
+    badge.textContent = count;
+    marker.appendChild(badge);
+  }
+  marker.addEventListener("click", (event) => {
+    event.stopPropagation();
+    if (count > 1 && view.zoom < maxZoom) {
+      setView(latitude, longitude, view.zoom + 2);
+    } else {
+      showPhoto(photo);
+    }
+  });
+  return marker;
+}
+
+function showPhoto(photo) {
+  document.querySelector("#full-image").src = photo.url;
+  document.querySelector("#full-caption .caption").textContent = photo.caption || "";
+  document.querySelector("#image-viewer").style.display = "block";
+}
+
+function hidePhoto() {
+  document.querySelector("#image-viewer").style.display = "none";
+}
+
+// zoomAround changes the zoom level keeping the point under the pointer in place
+function zoomAround(zoom, clientX, clientY) {
+  zoom = Math.max(0, Math.min(zoom, maxZoom));
+  if (zoom === view.zoom) {
+    return;
+  }
+  let rect = map.getBoundingClientRect();
+  let origin = topLeft();
+  let offsetX = clientX - rect.left;
+  let offsetY = clientY - rect.top;
+  let point = unproject(origin.x + offsetX, origin.y + offsetY, view.zoom);
+  let projected = project(point.latitude, point.longitude, zoom);
+  view.zoom = zoom;
+  view.x = projected.x - offsetX + map.clientWidth / 2;
+  view.y = projected.y - offsetY + map.clientHeight / 2;
+  tiles.replaceChildren();
+  render();
+}
+
+// fitPhotos shows every photo, or the position kept in the URL
+async function fitPhotos() {
+  let [zoom, latitude, longitude] = location.hash.slice(1).split("/").map(parseFloat);
+  if (!isNaN(zoom) && !isNaN(latitude) && !isNaN(longitude)) {
+    setView(latitude, longitude, Math.round(zoom));
+    return;
+  }
+
+  let features = await fetchClusters([-180, -90, 180, 90], 0);
+  if (features.length === 0) {
+    setView(20, 0, 2);
+    return;
+  }
+  let latitudes = features.map((f) => f.geometry.coordinates[1]);
+  let longitudes = features.map((f) => f.geometry.coordinates[0]);
+  let north = Math.max(...latitudes);
+  let south = Math.min(...latitudes);
+  let east = Math.max(...longitudes);
+  let west = Math.min(...longitudes);
+  let fitZoom = Math.min(PHOTO_ZOOM, maxZoom);
+  for (; fitZoom > 0; fitZoom--) {
+    let northWest = project(north, west, fitZoom);
+    let southEast = project(south, east, fitZoom);
+    if (southEast.x - northWest.x < map.clientWidth * 0.8 && southEast.y - northWest.y < map.clientHeight * 0.8) {
+      break;
+    }
+  }
+  setView((north + south) / 2, (east + west) / 2, fitZoom);
+}
+
+let drag = null;
+// a trackpad sends many wheel events for one gesture, so only the first one zooms
+let lastWheel = 0;
+map.addEventListener("pointerdown", (event) => {
+  if (event.target.closest(".marker, .zoom")) {
+    return;
+  }
+  drag = { x: event.clientX, y: event.clientY };
+  map.setPointerCapture(event.pointerId);
+  map.classList.add("dragging");
+});
+map.addEventListener("pointermove", (event) => {
+  if (!drag) {
+    return;
+  }
+  view.x -= event.clientX - drag.x;
+  view.y -= event.clientY - drag.y;
+  drag = { x: event.clientX, y: event.clientY };
+  render();
+});
+map.addEventListener("pointerup", () => {
+  drag = null;
+  map.classList.remove("dragging");
+});
+map.addEventListener(
+  "wheel",
+  (event) => {
+    event.preventDefault();
+    if (event.timeStamp - lastWheel < 250) {
+      return;
+    }
+    lastWheel = event.timeStamp;
+    zoomAround(view.zoom + (event.deltaY < 0 ? 1 : -1), event.clientX, event.clientY);
+  },
+  { passive: false },
+);
+map.addEventListener("dblclick", (event) => {
+  if (event.target.closest(".marker, .zoom")) {
+    return;
+  }
+  zoomAround(view.zoom + 1, event.clientX, event.clientY);
+});
+
+let middle = () => {
+  let rect = map.getBoundingClientRect();
+  return [rect.left + rect.width / 2, rect.top + rect.height / 2];
+};
+map.querySelector(".zoom-in").addEventListener("click", () => zoomAround(view.zoom + 1, ...middle()));
+map.querySelector(".zoom-out").addEventListener("click", () => zoomAround(view.zoom - 1, ...middle()));
+document.querySelector("#image-viewer .close").addEventListener("click", hidePhoto);
+document.body.addEventListener("keydown", (event) => {
+  if (event.key === "Escape") {
+    hidePhoto();
+  }
+});
+window.addEventListener("resize", render);
+
+fitPhotos();
@@ -0,0 +1,177 @@
+// Full screen slideshow for wall displays. The photos and settings are polled from the API, so
+// photos added to the library and config changes show without reloading the page.
+const POLL_INTERVAL = 30 * 1000;
+
+let show = document.querySelector("#slideshow");
+let slides = show.querySelectorAll(".slide");
+let caption = show.querySelector(".caption");
+
+let state = {
+  settings: {
+    interval: parseInt(show.dataset.interval, 10),
+    transition: show.dataset.transition,
+    shuffle: show.dataset.shuffle === "true",
+  },
+  // photos in the order they are shown
+  deck: [],
+  position: -1,
+  // index into slides of the one showing the current photo
+  front: 0,
+  timer: null,
+  paused: false,
+  // counts changes of photo, so a slow load doesn't replace a newer one
+  generation: 0,
+};
+
+function shuffled(photos) {
+  let copy = photos.slice();
+  for (let i = copy.length - 1; i > 0; i--) {
+    let j = Math.floor(Math.random() * (i + 1));
+    [copy[i], copy[j]] = [copy[j], copy[i]];
+  }
+  return copy;
+}
+
+// update merges the latest photos into the deck. Removed photos are dropped and new ones are shown next.
+function update(photos, settings) {
+  let latest = new Map(photos.map((photo) => [photo.id, photo]));
+  let known = new Set(state.deck.map((photo) => photo.id));
+  let added = photos.filter((photo) => !known.has(photo.id));
+
+  if (state.deck.length === 0) {
+    state.deck = settings.shuffle ? shuffled(added) : added;
+    state.position = -1;
+  } else {
+    let current = state.deck[state.position];
+    // photos that were removed are skipped, ones that remain pick up new captions
+    let before = state.deck.slice(0, state.position + 1).filter((photo) => latest.has(photo.id));
+    let after = state.deck.slice(state.position + 1).filter((photo) => latest.has(photo.id));
+    state.deck = [...before, ...added, ...after].map((photo) => latest.get(photo.id));
+    state.position = before.length - 1;
+    if (current && latest.has(current.id)) {
+      caption.textContent = latest.get(current.id).caption;
+    }
+  }
+
+  if (settings.shuffle !== state.settings.shuffle) {
+    let current = state.deck[state.position];
+    state.deck = settings.shuffle ? shuffled(photos) : photos;
+    state.position = current ? state.deck.findIndex((photo) => photo.id === current.id) : -1;
+  }
+
+  let intervalChanged = settings.interval !== state.settings.interval;
+  show.classList.replace("transition-" + state.settings.transition, "transition-" + settings.transition);
+  state.settings = settings;
+  if (intervalChanged) {
+    schedule();
+  }
+  show.classList.toggle("is-empty", state.deck.length === 0);
+}
+
+async function refresh() {
+  try {
+    let response = await fetch(show.dataset.photos, { cache: "no-cache" });
+    if (!response.ok) {
+      return;
+    }
+    let body = await response.json();
+    update(body.photos, body.settings);
+  } catch (err) {
+    // keep showing the photos already loaded until the server is back
+    console.warn("failed to refresh the slideshow", err);
+  }
+}
+
+function schedule() {
+  clearTimeout(state.timer);
+  if (!state.paused) {
+    state.timer = setTimeout(() => advance(1), state.settings.interval * 1000);
+  }
+}
+
+async function advance(step) {
+  if (state.deck.length === 0) {
+    schedule();
+    return;
+  }
+  let position = state.position + step;
+  if (position >= state.deck.length) {
+    position = 0;
+    if (state.settings.shuffle && state.deck.length > 1) {
+      // start the next round with a new order, without showing the last photo twice in a row
+      let last = state.deck[state.deck.length - 1];
+      do {
+        state.deck = shuffled(state.deck);
+      } while (state.deck[0].id === last.id);
+    }
+  } else if (position < 0) {
+    position = state.deck.length - 1;
+  }
+  state.position = position;
+  let photo = state.deck[position];
+  let generation = ++state.generation;
+
+  // load into the hidden slide, only swapping once it is ready to show
+  let back = slides[1 - state.front];
+  back.style.transition = "none";
+  back.classList.remove("visible", "leaving");
+  back.offsetWidth; // apply the reset before transitioning again
+  back.style.transition = "";
+  back.src = photo.url;
+  try {
+    await back.decode();
+  } catch (err) {
+    console.warn("failed to load photo", photo.id, err);
+  }
+  if (generation !== state.generation) {
+    return;
+  }
+
+  slides[state.front].classList.replace("visible", "leaving");
+  back.classList.add("visible");
+  state.front = 1 - state.front;
+  caption.textContent = photo.caption;
+
+  // the browser keeps the next photo cached, so the following swap doesn't wait for the network
+  let next = state.deck[(position + 1) % state.deck.length];
+  new Image().src = next.url;
+  schedule();
+}
+
+// wall displays shouldn't go to sleep while showing photos
+async function keepAwake() {
+  if (navigator.wakeLock && document.visibilityState === "visible") {
+    try {
+      await navigator.wakeLock.request("screen");
+    } catch (err) {
+      console.warn("failed to keep the screen awake", err);
+    }
+  }
+}
+
+document.body.addEventListener("keydown", (event) => {
+  switch (event.key) {
+    case "ArrowRight":
+      advance(1);
+      break;
+    case "ArrowLeft":
+      advance(-1);
+      break;
+    case " ":
+      state.paused = !state.paused;
+      schedule();
+      break;
+  }
+});
+show.addEventListener("click", () => {
+  if (document.fullscreenElement) {
+    document.exitFullscreen();
+  } else {
+    document.documentElement.requestFullscreen();
+  }
+});
+document.addEventListener("visibilitychange", keepAwake);
+
+setInterval(refresh, POLL_INTERVAL);
+keepAwake();
+refresh().then(() => advance(1));
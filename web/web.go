@@ -0,0 +1,10 @@
+// Package web holds the templates and static files of the site, embedded so the binary
+// can be started from any directory
+package web
+
+import "embed"
+
+// Files holds the template and static directories
+//
+//go:embed template static
+var Files embed.FS